@@ -9,6 +9,11 @@ type Query struct {
 	Needles  []string
 	By       By
 	Contains bool // if true, search for packages containing the needle, not exact matches
+
+	SortBy     SortBy // if unset, results are in the order of the backend
+	Descending bool
+	Limit      int // maximum number of results, 0 for no limit
+	Offset     int // number of results to skip, for pagination
 }
 
 // RequestEditorFn  is the function signature for the RequestEditor callback function.
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Jguer/aur/metadata"
+)
+
+const lookalikesMode = "lookalikes"
+
+// getLookalikes returns the lookalike report of each name.
+func getLookalikes(aurURL string, names []string, limit int) ([]metadata.LookalikeReport, error) {
+	client, _, err := newMetadataClient(aurURL)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]metadata.LookalikeReport, 0, len(names))
+
+	for _, name := range names {
+		report, errL := client.Lookalikes(context.Background(), name, &metadata.LookalikeOptions{Limit: limit})
+		if errL != nil {
+			return nil, fmt.Errorf("request failed: %w", errL)
+		}
+
+		reports = append(reports, *report)
+	}
+
+	return reports, nil
+}
+
+func printLookalikes(report *metadata.LookalikeReport, w io.Writer) {
+	switch {
+	case report.Pkg == nil:
+		fmt.Fprintf(w, "%s: not in the AUR\n", Bold(report.Name))
+	case report.Suspicious:
+		fmt.Fprintf(w, "%s: LIKELY IMPOSTOR\n", Bold(report.Name))
+	default:
+		fmt.Fprintf(w, "%s:\n", Bold(report.Name))
+	}
+
+	for i := range report.Lookalikes {
+		l := &report.Lookalikes[i]
+
+		maintainer := l.Pkg.Maintainer
+		if maintainer == "" {
+			maintainer = "orphan"
+		}
+
+		if l.SameMaintainer {
+			maintainer += ", same maintainer"
+		}
+
+		fmt.Fprintf(w, "- %s %.2f %s (%s, %d votes, %d days old)\n",
+			l.Pkg.Name, l.Similarity, l.Kind, maintainer, l.Pkg.NumVotes, int(l.Age/(24*time.Hour)))
+
+		if l.Impostor != "" {
+			fmt.Fprintf(w, "\tsuspected impostor %s: %s\n", l.Impostor, strings.Join(l.Reasons, ", "))
+		}
+	}
+}
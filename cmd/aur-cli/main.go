@@ -8,9 +8,12 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/Jguer/aur"
+	"github.com/Jguer/aur/metadata"
 	"github.com/Jguer/aur/rpc"
 )
 
@@ -27,6 +30,11 @@ const (
 	infoMode   = "info"
 )
 
+const (
+	rpcBackend      = "rpc"
+	metadataBackend = "metadata"
+)
+
 func getSearchBy(value string) aur.By {
 	switch value {
 	case "name":
@@ -60,14 +68,38 @@ func getSearchBy(value string) aur.By {
 	}
 }
 
+func getSortBy(value string) (aur.SortBy, error) {
+	switch value {
+	case "":
+		return 0, nil
+	case "name":
+		return aur.SortName, nil
+	case "votes":
+		return aur.SortVotes, nil
+	case "popularity":
+		return aur.SortPopularity, nil
+	case "modified":
+		return aur.SortLastModified, nil
+	case "submitted":
+		return aur.SortFirstSubmitted, nil
+	default:
+		return 0, fmt.Errorf("invalid sort field: %s", value)
+	}
+}
+
 func usage() {
 	fmt.Println("Usage:", os.Args[0], "<opts>", "<command>", "<pkg(s)>")
-	fmt.Println("Available commands:", "info, search")
+	fmt.Println("Available commands:", "info, search, trends, movers, abandoned, lookalikes, trust")
 	fmt.Println("Available opts:", "-by <Search for packages using a specified field>")
 
 	flag.Usage()
 
 	fmt.Println("Example:", "aur-cli -verbose -by name search python3.7")
+	fmt.Println("Example:", "aur-cli -sort votes -desc -limit 20 search python")
+	fmt.Println("Example:", "aur-cli -backend metadata info yay")
+	fmt.Println("Example:", "aur-cli -since 720h -sort popularity -limit 10 movers")
+	fmt.Println("Example:", "aur-cli lookalikes google-chrome")
+	fmt.Println("Example:", "aur-cli -trust-config weights.json trust yay")
 }
 
 func versionRequestEditor(ctx context.Context, req *http.Request) error {
@@ -82,6 +114,13 @@ func main() {
 		aurURL      string
 		verbose     bool
 		jsonDisplay bool
+		sortBy      string
+		descending  bool
+		limit       int
+		offset      int
+		backend     string
+		trendOpts   trendOptions
+		trustConfig string
 	)
 
 	flag.StringVar(&by, "by", "name-desc", "Search for packages using a specified field"+
@@ -90,75 +129,223 @@ func main() {
 	flag.StringVar(&aurURL, "url", "https://aur.archlinux.org/", "AUR URL")
 	flag.BoolVar(&verbose, "verbose", false, "display verbose information")
 	flag.BoolVar(&jsonDisplay, "json", false, "display result as JSON")
+	flag.StringVar(&sortBy, "sort", "", "Sort results by a field"+
+		"\n (name/votes/popularity/modified/submitted)")
+	flag.BoolVar(&descending, "desc", false, "sort in descending order")
+	flag.IntVar(&limit, "limit", 0, "maximum number of results")
+	flag.IntVar(&offset, "offset", 0, "number of results to skip")
+	flag.StringVar(&backend, "backend", rpcBackend, "Query the AUR through"+
+		"\n (rpc/metadata, the metadata dump is downloaded once and cached)")
+	flag.DurationVar(&trendOpts.since, "since", 30*24*time.Hour, "period of the trend commands"+
+		"\n (trends are sampled whenever the metadata backend downloads the dump)")
+	flag.BoolVar(&trendOpts.falling, "falling", false, "list the falling movers instead of the rising ones")
+	flag.Float64Var(&trendOpts.maxPopularity, "max-popularity", 0.01, "highest popularity of abandoned packages")
+	flag.StringVar(&trustConfig, "trust-config", "", "JSON file tuning the trust score weights")
 	flag.Parse()
 
-	if flag.NArg() < 2 {
+	mode := flag.Arg(0)
+
+	if flag.NArg() < 2 && mode != moversMode && mode != abandonedMode {
 		usage()
 
 		os.Exit(1)
 	}
 
-	mode := flag.Arg(0)
+	if isTrendMode(mode) {
+		trendOpts.sortBy = sortBy
+		trendOpts.limit = limit
+
+		results, err := getTrends(aurURL, mode, flag.Args()[1:], &trendOpts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+
+			os.Exit(1)
+		}
+
+		display(results, jsonDisplay, func(i int) { printGrowth(&results[i], os.Stdout) })
+
+		return
+	}
+
+	if mode == lookalikesMode {
+		reports, err := getLookalikes(aurURL, flag.Args()[1:], limit)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+
+			os.Exit(1)
+		}
+
+		display(reports, jsonDisplay, func(i int) { printLookalikes(&reports[i], os.Stdout) })
+
+		// lets scripts refuse dependencies on likely impostors
+		for i := range reports {
+			if reports[i].Suspicious {
+				os.Exit(2)
+			}
+		}
+
+		return
+	}
 
-	aurClient, err := rpc.NewClient(rpc.WithBaseURL(aurURL),
-		rpc.WithRequestEditorFn(versionRequestEditor), rpc.WithLogFn(func(s ...any) {
-			fmt.Fprintln(os.Stdout, append([]any{"[DEBUG]"}, s...)...)
-		}))
+	sortField, err := getSortBy(sortBy)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
+
+		os.Exit(1)
 	}
 
-	results, err := getResults(aurClient, by, mode)
+	page := aur.Query{SortBy: sortField, Descending: descending, Limit: limit, Offset: offset}
+
+	aurClient, err := newClient(backend, aurURL)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 
 		os.Exit(1)
 	}
 
-	if jsonDisplay {
-		output, err := json.MarshalIndent(results, "", "  ")
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
+	if mode == trustMode {
+		model, errM := loadTrustModel(trustConfig)
+		if errM != nil {
+			fmt.Fprintln(os.Stderr, errM)
 
 			os.Exit(1)
 		}
 
-		fmt.Println(string(output))
-	} else {
+		scores, errT := getTrust(aurClient, flag.Args()[1:], &model)
+		if errT != nil {
+			fmt.Fprintln(os.Stderr, errT)
+
+			os.Exit(1)
+		}
+
+		display(scores, jsonDisplay, func(i int) { printTrust(&scores[i], os.Stdout) })
+
+		return
+	}
+
+	results, err := getResults(aurClient, by, mode, page)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		os.Exit(1)
+	}
+
+	display(results, jsonDisplay, func(i int) {
+		switch mode {
+		case infoMode:
+			printInfo(&results[i], os.Stdout, aurURL, verbose)
+		case searchMode:
+			printSearch(&results[i], os.Stdout)
+		default:
+			usage()
+			os.Exit(1)
+		}
+	})
+}
+
+// display prints results as JSON, or each of them with printFn.
+func display[T any](results []T, jsonDisplay bool, printFn func(i int)) {
+	if !jsonDisplay {
 		for i := range results {
-			switch mode {
-			case infoMode:
-				printInfo(&results[i], os.Stdout, aurURL, verbose)
-			case searchMode:
-				printSearch(&results[i], os.Stdout)
-			default:
-				usage()
-				os.Exit(1)
-			}
+			printFn(i)
 		}
+
+		return
 	}
+
+	output, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		os.Exit(1)
+	}
+
+	fmt.Println(string(output))
 }
 
-func getResults(aurClient *rpc.Client, by, mode string) ([]aur.Pkg, error) {
+func debugLog(s ...any) {
+	fmt.Fprintln(os.Stdout, append([]any{"[DEBUG]"}, s...)...)
+}
+
+// newClient creates the client of backend.
+func newClient(backend, aurURL string) (rpc.ClientInterface, error) {
+	switch backend {
+	case rpcBackend:
+		return rpc.NewClient(rpc.WithBaseURL(aurURL),
+			rpc.WithRequestEditorFn(versionRequestEditor), rpc.WithLogFn(debugLog))
+	case metadataBackend:
+		client, _, err := newMetadataClient(aurURL)
+		if err != nil {
+			return nil, err
+		}
+
+		return client, nil
+	default:
+		return nil, fmt.Errorf("invalid backend: %s", backend)
+	}
+}
+
+// newMetadataClient creates a metadata client caching in the user cache
+// directory, which samples the metadata into the returned trend store.
+func newMetadataClient(aurURL string) (*metadata.Client, *metadata.Trends, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cacheDir = filepath.Join(cacheDir, "aur-cli")
+	if errM := os.MkdirAll(cacheDir, 0o700); errM != nil {
+		return nil, nil, errM
+	}
+
+	trends, err := metadata.OpenTrends(filepath.Join(cacheDir, "trends"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := []metadata.ClientOption{
+		metadata.WithBaseURL(aurURL),
+		metadata.WithRequestEditorFn(versionRequestEditor), metadata.WithDebugLogger(debugLog),
+		metadata.WithCacheFilePath(filepath.Join(cacheDir, "packages-meta-ext-v1.json")),
+		metadata.WithTrends(trends),
+	}
+
+	if isTerminal(os.Stderr) {
+		opts = append(opts, metadata.WithProgress(progressPrinter(os.Stderr)))
+	}
+
+	client, err := metadata.New(opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, trends, nil
+}
+
+// getResults runs the query for mode, sorted and paginated like page.
+func getResults(aurClient aur.QueryClient, by, mode string, page aur.Query) ([]aur.Pkg, error) {
 	var (
 		results []aur.Pkg
 		err     error
 	)
 
+	query := page
+	query.Needles = flag.Args()[1:]
+
 	switch mode {
 	case searchMode:
-		results, err = aurClient.Get(context.Background(), &aur.Query{
-			Needles: flag.Args()[1:], By: getSearchBy(by), Contains: true,
-		})
+		query.By = getSearchBy(by)
+		query.Contains = true
+		results, err = aurClient.Get(context.Background(), &query)
 	case infoMode:
-		results, err = aurClient.Get(context.Background(), &aur.Query{Needles: flag.Args()[1:]})
+		results, err = aurClient.Get(context.Background(), &query)
 	default:
 		usage()
 		os.Exit(1)
 	}
 
 	if err != nil {
-		err = fmt.Errorf("rpc request failed: %w", err)
+		err = fmt.Errorf("request failed: %w", err)
 	}
 
 	return results, err
@@ -2,12 +2,22 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/Jguer/aur"
+	"github.com/Jguer/aur/metadata"
+	"github.com/Jguer/aur/rpc"
+	"github.com/Jguer/aur/trust"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_printSearch(t *testing.T) {
@@ -69,3 +79,182 @@ func Test_printInfo(t *testing.T) {
 		})
 	}
 }
+
+func Test_getSortBy(t *testing.T) {
+	got, err := getSortBy("")
+	assert.NoError(t, err)
+	assert.Equal(t, aur.SortBy(0), got)
+
+	for _, by := range []aur.SortBy{
+		aur.SortName, aur.SortVotes, aur.SortPopularity, aur.SortLastModified, aur.SortFirstSubmitted,
+	} {
+		got, err := getSortBy(by.String())
+		assert.NoError(t, err)
+		assert.Equal(t, by, got)
+	}
+
+	_, err = getSortBy("size")
+	assert.Error(t, err)
+}
+
+func Test_newClient(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	client, err := newClient(rpcBackend, "https://aur.archlinux.org/")
+	assert.NoError(t, err)
+	assert.IsType(t, &rpc.Client{}, client)
+
+	client, err = newClient(metadataBackend, "https://aur.archlinux.org/")
+	assert.NoError(t, err)
+	assert.IsType(t, &metadata.Client{}, client)
+
+	_, err = newClient("carrier-pigeon", "https://aur.archlinux.org/")
+	assert.Error(t, err)
+}
+
+func Test_formatProgress(t *testing.T) {
+	assert.Equal(t, "download [###############---------------]  50% 1.0 MiB/2.0 MiB 1.5s",
+		formatProgress(metadata.Progress{
+			Phase: metadata.PhaseDownload, Bytes: 1 << 20, Total: 2 << 20, Elapsed: 1520 * time.Millisecond,
+		}))
+	assert.Equal(t, "parse    0.5 MiB 200ms",
+		formatProgress(metadata.Progress{
+			Phase: metadata.PhaseParse, Bytes: 1 << 19, Total: -1, Elapsed: 250 * time.Millisecond,
+		}))
+
+	var b bytes.Buffer
+
+	progressPrinter(&b)(metadata.Progress{Phase: metadata.PhaseParse, Bytes: 0, Total: -1, Done: true})
+	assert.Equal(t, "\rparse    0.0 MiB 0s\n", b.String())
+}
+
+func Test_getTrends(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"Name":"yay","NumVotes":10,"Popularity":1.5},{"Name":"paru","NumVotes":5}]`)
+	}))
+	defer server.Close()
+
+	opts := &trendOptions{since: time.Hour}
+
+	results, err := getTrends(server.URL, trendsMode, []string{"yay", "missing"}, opts)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "yay", results[0].Name)
+	assert.Equal(t, 10, results[0].To.NumVotes)
+	assert.Zero(t, results[0].Votes)
+
+	// a single sample has no movers
+	results, err = getTrends(server.URL, moversMode, nil, opts)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	opts.sortBy = "name"
+	_, err = getTrends(server.URL, moversMode, nil, opts)
+	assert.Error(t, err)
+}
+
+func Test_printGrowth(t *testing.T) {
+	var b bytes.Buffer
+
+	day := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	printGrowth(&metadata.Growth{
+		Name:        "yay",
+		From:        metadata.Sample{Time: day, NumVotes: 10, Popularity: 1},
+		To:          metadata.Sample{Time: day.Add(48 * time.Hour), NumVotes: 14, Popularity: 0.5},
+		Votes:       4,
+		Popularity:  -0.5,
+		VotesPerDay: 2,
+	}, &b)
+
+	assert.Equal(t, "- "+Bold("yay")+" votes 14 (+4, 2.00/day) popularity 0.50 (-0.50) since 2022-01-01\n", b.String())
+}
+
+func Test_getLookalikes(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"Name":"google-chrome","Maintainer":"google","NumVotes":2000,"FirstSubmitted":1},`+
+			`{"Name":"google-chrone","Maintainer":"mallory","NumVotes":1,"FirstSubmitted":2}]`)
+	}))
+	defer server.Close()
+
+	reports, err := getLookalikes(server.URL, []string{"google-chrone", "paru"}, 0)
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+	assert.True(t, reports[0].Suspicious)
+	assert.Nil(t, reports[1].Pkg)
+	assert.Empty(t, reports[1].Lookalikes)
+}
+
+func Test_printLookalikes(t *testing.T) {
+	var b bytes.Buffer
+
+	printLookalikes(&metadata.LookalikeReport{
+		Name:       "yau",
+		Pkg:        &aur.Pkg{Name: "yau"},
+		Suspicious: true,
+		Lookalikes: []metadata.Lookalike{{
+			Pkg:        aur.Pkg{Name: "yay", Maintainer: "jguer", NumVotes: 2000},
+			Similarity: 0.67,
+			Kind:       metadata.LookalikeTypo,
+			Age:        72 * time.Hour,
+			Impostor:   "yau",
+			Reasons:    []string{"named like yay (typo)", "orphaned"},
+		}},
+	}, &b)
+
+	assert.Equal(t, Bold("yau")+": LIKELY IMPOSTOR\n"+
+		"- yay 0.67 typo (jguer, 2000 votes, 3 days old)\n"+
+		"\tsuspected impostor yau: named like yay (typo), orphaned\n", b.String())
+}
+
+type staticQueryClient []aur.Pkg
+
+func (c staticQueryClient) Get(ctx context.Context, query *aur.Query) ([]aur.Pkg, error) {
+	return c, nil
+}
+
+func Test_getTrust(t *testing.T) {
+	model, err := loadTrustModel("")
+	require.NoError(t, err)
+	assert.Equal(t, trust.DefaultModel, model)
+
+	path := filepath.Join(t.TempDir(), "weights.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"Weights": {"Maintainer": 0}}`), 0o600))
+
+	model, err = loadTrustModel(path)
+	require.NoError(t, err)
+	assert.Zero(t, model.Weights.Maintainer)
+
+	results, err := getTrust(staticQueryClient{{Name: "yay", Maintainer: "jguer"}}, []string{"yay"}, &model)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "yay", results[0].Name)
+
+	for _, f := range results[0].Factors {
+		assert.NotEqual(t, "maintainer", f.Signal)
+	}
+
+	_, err = loadTrustModel(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func Test_printTrust(t *testing.T) {
+	var b bytes.Buffer
+
+	printTrust(&trust.Result{
+		Name:  "yay",
+		Score: 87.4,
+		Factors: []trust.Factor{
+			{Signal: "votes", Points: 30.25, Reason: "2000 votes"},
+			{Signal: "maintainer", Points: 0, Reason: "orphaned"},
+		},
+	}, &b)
+
+	assert.Equal(t, Bold("yay")+" 87/100\n"+
+		"\tvotes           30.2  2000 votes\n"+
+		"\tmaintainer       0.0  orphaned\n", b.String())
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Jguer/aur/metadata"
+)
+
+const progressBarWidth = 30
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// progressPrinter returns a metadata.ProgressFn redrawing a progress bar
+// on w.
+func progressPrinter(w io.Writer) metadata.ProgressFn {
+	return func(p metadata.Progress) {
+		fmt.Fprint(w, "\r"+formatProgress(p))
+
+		if p.Done {
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+func formatProgress(p metadata.Progress) string {
+	elapsed := p.Elapsed.Truncate(100 * time.Millisecond).String()
+
+	if p.Total <= 0 {
+		return fmt.Sprintf("%-8s %s %s", p.Phase, formatMiB(p.Bytes), elapsed)
+	}
+
+	ratio := float64(p.Bytes) / float64(p.Total)
+	if ratio > 1 {
+		ratio = 1
+	}
+
+	filled := int(ratio * progressBarWidth)
+
+	return fmt.Sprintf("%-8s [%s%s] %3.0f%% %s/%s %s", p.Phase,
+		strings.Repeat("#", filled), strings.Repeat("-", progressBarWidth-filled),
+		ratio*100, formatMiB(p.Bytes), formatMiB(p.Total), elapsed)
+}
+
+func formatMiB(b int64) string {
+	return fmt.Sprintf("%.1f MiB", float64(b)/(1<<20))
+}
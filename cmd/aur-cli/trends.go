@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Jguer/aur/metadata"
+)
+
+const (
+	trendsMode    = "trends"
+	moversMode    = "movers"
+	abandonedMode = "abandoned"
+)
+
+// trendOptions are the flags of the trend commands.
+type trendOptions struct {
+	since         time.Duration
+	sortBy        string
+	falling       bool
+	limit         int
+	maxPopularity float64
+}
+
+func isTrendMode(mode string) bool {
+	return mode == trendsMode || mode == moversMode || mode == abandonedMode
+}
+
+func getMetric(value string) (metadata.Metric, error) {
+	switch value {
+	case "", "votes":
+		return metadata.MetricVotes, nil
+	case "popularity":
+		return metadata.MetricPopularity, nil
+	default:
+		return 0, fmt.Errorf("invalid trend metric: %s", value)
+	}
+}
+
+// getTrends loads the metadata, sampling it, and runs the trend query of mode
+// over the samples recorded by previous runs.
+func getTrends(aurURL, mode string, names []string, opts *trendOptions) ([]metadata.Growth, error) {
+	ctx := context.Background()
+
+	client, trends, err := newMetadataClient(aurURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, errL := client.Load(ctx); errL != nil {
+		return nil, fmt.Errorf("request failed: %w", errL)
+	}
+
+	since := time.Now().Add(-opts.since)
+
+	switch mode {
+	case trendsMode:
+		results := make([]metadata.Growth, 0, len(names))
+
+		for _, name := range names {
+			growth, errG := trends.PackageGrowth(name, since)
+			if errG != nil {
+				return nil, errG
+			}
+
+			if growth != nil {
+				results = append(results, *growth)
+			}
+		}
+
+		return results, nil
+	case moversMode:
+		metric, errM := getMetric(opts.sortBy)
+		if errM != nil {
+			return nil, errM
+		}
+
+		return trends.TopMovers(metadata.MoversOptions{
+			Since: since, By: metric, Falling: opts.falling, Top: opts.limit,
+		})
+	case abandonedMode:
+		return trends.Abandoned(metadata.AbandonedOptions{Since: since, MaxPopularity: opts.maxPopularity})
+	default:
+		return nil, fmt.Errorf("invalid trend command: %s", mode)
+	}
+}
+
+func printGrowth(g *metadata.Growth, w io.Writer) {
+	fmt.Fprintf(w, "- %s votes %d (%+d, %.2f/day) popularity %.2f (%+.2f) since %s\n",
+		Bold(g.Name), g.To.NumVotes, g.Votes, g.VotesPerDay,
+		g.To.Popularity, g.Popularity, g.From.Time.Format("2006-01-02"))
+}
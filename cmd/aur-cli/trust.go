@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Jguer/aur"
+	"github.com/Jguer/aur/trust"
+)
+
+const trustMode = "trust"
+
+// loadTrustModel reads the model at path, or returns the default one if
+// path is empty.
+func loadTrustModel(path string) (trust.Model, error) {
+	if path == "" {
+		return trust.DefaultModel, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return trust.Model{}, err
+	}
+	defer f.Close()
+
+	return trust.LoadModel(f)
+}
+
+// getTrust scores the packages called names.
+func getTrust(aurClient aur.QueryClient, names []string, model *trust.Model) ([]trust.Result, error) {
+	pkgs, err := aurClient.Get(context.Background(), &aur.Query{Needles: names, By: aur.Name})
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	now := time.Now()
+	results := make([]trust.Result, 0, len(pkgs))
+
+	for i := range pkgs {
+		results = append(results, model.Score(&pkgs[i], now))
+	}
+
+	return results, nil
+}
+
+func printTrust(result *trust.Result, w io.Writer) {
+	fmt.Fprintf(w, "%s %.0f/100\n", Bold(result.Name), result.Score)
+
+	for _, f := range result.Factors {
+		fmt.Fprintf(w, "\t%-14s %5.1f  %s\n", f.Signal, f.Points, f.Reason)
+	}
+}
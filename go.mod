@@ -4,12 +4,9 @@ go 1.19
 
 require (
 	github.com/itchyny/gojq v0.12.11
-	github.com/ohler55/ojg v1.15.0
 	github.com/stretchr/testify v1.8.1
 )
 
-require github.com/mitchellh/mapstructure v1.5.0
-
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/hashicorp/errwrap v1.0.0 // indirect
@@ -0,0 +1,175 @@
+// Package conformance checks that AUR clients answer Search and Info like
+// the aurweb RPC, so they can be used interchangeably.
+package conformance
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/Jguer/aur"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Client is the part of rpc.ClientInterface under test.
+type Client interface {
+	aur.QueryClient
+	Search(ctx context.Context, query string, by aur.By) ([]aur.Pkg, error)
+	Info(ctx context.Context, pkgs []string) ([]aur.Pkg, error)
+}
+
+// NewClientFn creates the client under test, serving pkgs.
+type NewClientFn func(t *testing.T, pkgs []aur.Pkg) Client
+
+// Pkgs are the packages the suite runs against.
+func Pkgs() []aur.Pkg {
+	return []aur.Pkg{
+		{
+			ID:            1,
+			Name:          "yay",
+			PackageBaseID: 1,
+			PackageBase:   "yay",
+			Version:       "11.3.0-1",
+			Description:   "Yet another yogurt. Pacman wrapper and AUR helper written in go.",
+			NumVotes:      1855,
+			Popularity:    31.5,
+			Maintainer:    "jguer",
+			Submitter:     "jguer",
+			Depends:       []string{"pacman>5", "git"},
+			MakeDepends:   []string{"go>=1.17"},
+			Keywords:      []string{"arch", "aur", "helper"},
+			CoMaintainers: []string{"morganamilo"},
+		},
+		{
+			ID:            2,
+			Name:          "yay-bin",
+			PackageBaseID: 2,
+			PackageBase:   "yay-bin",
+			Version:       "11.3.0-1",
+			Description:   "Pacman wrapper and AUR helper written in go. Pre-compiled.",
+			NumVotes:      120,
+			Popularity:    2.1,
+			Maintainer:    "jguer",
+			Submitter:     "jguer",
+			Depends:       []string{"pacman>5", "git"},
+			Provides:      []string{"yay=11.3.0"},
+			Conflicts:     []string{"yay"},
+		},
+		{
+			ID:            3,
+			Name:          "paru",
+			PackageBaseID: 3,
+			PackageBase:   "paru",
+			Version:       "1.11.1-1",
+			Description:   "Feature packed AUR helper",
+			NumVotes:      550,
+			Popularity:    20.3,
+			Maintainer:    "Morganamilo",
+			Submitter:     "Morganamilo",
+			Depends:       []string{"git", "pacman"},
+			MakeDepends:   []string{"cargo"},
+			OptDepends:    []string{"bat: colored pkgbuild printing"},
+			Keywords:      []string{"aur", "helper"},
+		},
+		{
+			ID:            4,
+			Name:          "python-foo",
+			PackageBaseID: 4,
+			PackageBase:   "python-foo",
+			Version:       "0.1-1",
+			Description:   "An orphaned library",
+			Submitter:     "someone",
+			Depends:       []string{"python"},
+			CheckDepends:  []string{"python-pytest"},
+			Replaces:      []string{"python-oldfoo"},
+			Groups:        []string{"foo-libs"},
+		},
+	}
+}
+
+// Run runs the conformance suite against the clients created by newClient.
+func Run(t *testing.T, newClient NewClientFn) {
+	t.Helper()
+
+	client := newClient(t, Pkgs())
+	ctx := context.Background()
+
+	searches := []struct {
+		query string
+		by    aur.By
+		want  []string
+	}{
+		{"yay", aur.None, []string{"yay", "yay-bin"}},
+		{"YAY", aur.NameDesc, []string{"yay", "yay-bin"}},
+		{"helper", aur.None, []string{"paru", "yay", "yay-bin"}},
+		{"helper", aur.Name, []string{}},
+		{"ru", aur.Name, []string{"paru"}},
+		{"jguer", aur.Maintainer, []string{"yay", "yay-bin"}},
+		{"", aur.Maintainer, []string{"python-foo"}},
+		{"someone", aur.Submitter, []string{"python-foo"}},
+		{"pacman", aur.Depends, []string{"paru", "yay", "yay-bin"}},
+		{"go", aur.MakeDepends, []string{"yay"}},
+		{"bat", aur.OptDepends, []string{"paru"}},
+		{"python-pytest", aur.CheckDepends, []string{"python-foo"}},
+		{"yay", aur.Provides, []string{"yay-bin"}},
+		{"yay", aur.Conflicts, []string{"yay-bin"}},
+		{"python-oldfoo", aur.Replaces, []string{"python-foo"}},
+		{"arch", aur.Keywords, []string{"yay"}},
+		{"foo-libs", aur.Groups, []string{"python-foo"}},
+		{"morganamilo", aur.CoMaintainers, []string{"yay"}},
+	}
+
+	for _, search := range searches {
+		search := search
+
+		t.Run("search/"+search.by.String()+"/"+search.query, func(t *testing.T) {
+			pkgs, err := client.Search(ctx, search.query, search.by)
+			require.NoError(t, err)
+			assert.Equal(t, search.want, names(pkgs))
+
+			for i := range pkgs {
+				assert.Empty(t, pkgs[i].Depends, "search results only carry basic fields")
+			}
+		})
+	}
+
+	t.Run("search/too small", func(t *testing.T) {
+		_, err := client.Search(ctx, "y", aur.None)
+
+		var payloadErr *aur.PayloadError
+		require.True(t, errors.As(err, &payloadErr), err)
+		assert.Equal(t, "Query arg too small.", payloadErr.ErrorField)
+	})
+
+	t.Run("info", func(t *testing.T) {
+		pkgs, err := client.Info(ctx, []string{"yay", "missing", "paru"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"paru", "yay"}, names(pkgs))
+
+		for i := range pkgs {
+			if pkgs[i].Name == "yay" {
+				assert.Equal(t, Pkgs()[0], pkgs[i])
+			}
+		}
+	})
+	// exact queries without By are info lookups
+	t.Run("get/unset by", func(t *testing.T) {
+		pkgs, err := client.Get(ctx, &aur.Query{Needles: []string{"yay", "missing", "paru"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"paru", "yay"}, names(pkgs))
+	})
+}
+
+// names returns the sorted names of pkgs, aurweb doesn't order results.
+func names(pkgs []aur.Pkg) []string {
+	out := make([]string, 0, len(pkgs))
+	for i := range pkgs {
+		out = append(out, pkgs[i].Name)
+	}
+
+	sort.Strings(out)
+
+	return out
+}
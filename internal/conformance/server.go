@@ -0,0 +1,162 @@
+package conformance
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Jguer/aur"
+)
+
+// NewServer starts a minimal aurweb RPC v5 serving pkgs, for clients
+// talking to the RPC. It is closed when the test ends.
+func NewServer(t *testing.T, pkgs []aur.Pkg) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		values := r.URL.Query()
+
+		var (
+			results []aur.Pkg
+			errMsg  string
+		)
+
+		switch values.Get("type") {
+		case "info":
+			results = info(pkgs, values["arg[]"])
+		case "search":
+			results, errMsg = search(pkgs, values.Get("arg"), values.Get("by"))
+		default:
+			errMsg = "Incorrect request type specified."
+		}
+
+		resp := map[string]any{"version": 5, "type": values.Get("type"), "resultcount": len(results), "results": results}
+		if errMsg != "" {
+			resp = map[string]any{"version": 5, "type": "error", "resultcount": 0, "results": []aur.Pkg{}, "error": errMsg}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			t.Error(err)
+		}
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func info(pkgs []aur.Pkg, names []string) []aur.Pkg {
+	results := []aur.Pkg{}
+
+	for i := range pkgs {
+		for _, name := range names {
+			if pkgs[i].Name == name {
+				results = append(results, pkgs[i])
+
+				break
+			}
+		}
+	}
+
+	return results
+}
+
+func search(pkgs []aur.Pkg, arg, by string) ([]aur.Pkg, string) {
+	if by == "" {
+		by = "name-desc"
+	}
+
+	if (by == "name" || by == "name-desc") && len(arg) < 2 {
+		return nil, "Query arg too small."
+	}
+
+	results := []aur.Pkg{}
+
+	for i := range pkgs {
+		pkg := &pkgs[i]
+
+		var match bool
+
+		switch by {
+		case "name":
+			match = containsFold(pkg.Name, arg)
+		case "name-desc":
+			match = containsFold(pkg.Name, arg) || containsFold(pkg.Description, arg)
+		case "maintainer":
+			match = strings.EqualFold(pkg.Maintainer, arg)
+		case "submitter":
+			match = strings.EqualFold(pkg.Submitter, arg)
+		case "depends":
+			match = hasRelation(pkg.Depends, arg)
+		case "makedepends":
+			match = hasRelation(pkg.MakeDepends, arg)
+		case "optdepends":
+			match = hasRelation(pkg.OptDepends, arg)
+		case "checkdepends":
+			match = hasRelation(pkg.CheckDepends, arg)
+		case "provides":
+			match = hasRelation(pkg.Provides, arg)
+		case "conflicts":
+			match = hasRelation(pkg.Conflicts, arg)
+		case "replaces":
+			match = hasRelation(pkg.Replaces, arg)
+		case "keywords":
+			for _, keyword := range strings.Fields(arg) {
+				match = match || hasRelation(pkg.Keywords, keyword)
+			}
+		case "groups":
+			match = hasRelation(pkg.Groups, arg)
+		case "comaintainers":
+			match = hasRelation(pkg.CoMaintainers, arg)
+		default:
+			return nil, "Incorrect by field specified."
+		}
+
+		if match {
+			// search results only carry the basic fields
+			results = append(results, aur.Pkg{
+				ID:             pkg.ID,
+				Name:           pkg.Name,
+				PackageBaseID:  pkg.PackageBaseID,
+				PackageBase:    pkg.PackageBase,
+				Version:        pkg.Version,
+				Description:    pkg.Description,
+				URL:            pkg.URL,
+				NumVotes:       pkg.NumVotes,
+				Popularity:     pkg.Popularity,
+				OutOfDate:      pkg.OutOfDate,
+				Maintainer:     pkg.Maintainer,
+				Submitter:      pkg.Submitter,
+				FirstSubmitted: pkg.FirstSubmitted,
+				LastModified:   pkg.LastModified,
+				URLPath:        pkg.URLPath,
+			})
+		}
+	}
+
+	return results, ""
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// hasRelation reports whether relations names name, ignoring versions and
+// descriptions.
+func hasRelation(relations []string, name string) bool {
+	for _, rel := range relations {
+		if i := strings.IndexAny(rel, "<>=:"); i >= 0 {
+			rel = rel[:i]
+		}
+
+		if strings.EqualFold(rel, name) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,12 @@
+// Package curve holds the curves shared by search ranking and trust scoring.
+package curve
+
+// Saturate maps v >= 0 to [0, 1), reaching 0.5 at half. It keeps large
+// counts like votes from outweighing every other signal.
+func Saturate(v, half float64) float64 {
+	if v <= 0 {
+		return 0
+	}
+
+	return v / (v + half)
+}
@@ -0,0 +1,17 @@
+package curve
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaturate(t *testing.T) {
+	t.Parallel()
+
+	assert.Zero(t, Saturate(-1, 10))
+	assert.Zero(t, Saturate(0, 10))
+	assert.InDelta(t, 0.5, Saturate(10, 10), 1e-9)
+	assert.Less(t, Saturate(1e9, 10), 1.0)
+	assert.Less(t, Saturate(5, 10), Saturate(6, 10))
+}
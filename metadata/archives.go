@@ -0,0 +1,105 @@
+package metadata
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// PackageNames returns the names of all AUR packages. It only downloads the
+// small PackagesArchive, which makes it suited for shell completion.
+func (a *Client) PackageNames(ctx context.Context) ([]string, error) {
+	return a.nameList(ctx, PackagesArchive)
+}
+
+// PackageBases returns the names of all AUR package bases.
+func (a *Client) PackageBases(ctx context.Context) ([]string, error) {
+	return a.nameList(ctx, PkgBaseArchive)
+}
+
+// Users returns the names of all AUR users.
+func (a *Client) Users(ctx context.Context) ([]string, error) {
+	return a.nameList(ctx, UsersArchive)
+}
+
+// archiveCachePath returns where archive is cached, next to the cache file.
+func (a *Client) archiveCachePath(archive string) string {
+	return a.cacheBasePath + "-" + archive
+}
+
+// nameList returns the lines of a name list archive. The archive is cached
+// with the same validity, offline and stale fallback rules as the metadata.
+func (a *Client) nameList(ctx context.Context, archive string) ([]string, error) {
+	if a.cacheBasePath == "" {
+		return nil, ErrNoSource
+	}
+
+	path := a.archiveCachePath(archive)
+
+	update, err := a.expired(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if update && !a.offline {
+		if errD := a.cacheArchive(ctx, archive, path); errD != nil {
+			if _, errS := os.Stat(path); errS != nil || !a.staleFallback || isContextErr(errD) {
+				return nil, errD
+			}
+
+			if a.debugLoggerFn != nil {
+				a.debugLoggerFn("AUR archive refresh failed, using stale cache", archive, errD)
+			}
+		}
+	}
+
+	r, err := openCache(path, nil, nil)
+	if err != nil {
+		if a.offline && errors.Is(err, os.ErrNotExist) {
+			return nil, ErrOffline
+		}
+
+		return nil, err
+	}
+	defer r.Close()
+
+	return readNameList(r)
+}
+
+func (a *Client) cacheArchive(ctx context.Context, archive, path string) error {
+	if a.debugLoggerFn != nil {
+		a.debugLoggerFn("AUR archive is out of date, updating", archive)
+	}
+
+	body, err := a.downloadArchive(ctx, archive)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	return writeCache(path, body)
+}
+
+// readNameList returns the non empty lines of r, skipping comments.
+func readNameList(r io.Reader) ([]string, error) {
+	names := []string{}
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		names = append(names, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
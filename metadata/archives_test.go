@@ -0,0 +1,152 @@
+package metadata
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// archiveMockHTTP serves archives by file name.
+type archiveMockHTTP struct {
+	mu       sync.Mutex
+	archives map[string][]byte
+	requests []string
+}
+
+func (m *archiveMockHTTP) Do(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name := path.Base(req.URL.Path)
+	m.requests = append(m.requests, name)
+
+	body, ok := m.archives[name]
+	if !ok {
+		return &http.Response{
+			StatusCode: http.StatusNotFound,
+			Status:     "404 Not Found",
+			Body:       io.NopCloser(bytes.NewReader(nil)),
+		}, nil
+	}
+
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(body))}, nil
+}
+
+func gzipBytes(t *testing.T, s string) []byte {
+	t.Helper()
+
+	var b bytes.Buffer
+
+	zw := gzip.NewWriter(&b)
+	_, err := zw.Write([]byte(s))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	return b.Bytes()
+}
+
+func TestClientNameLists(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	mock := &archiveMockHTTP{archives: map[string][]byte{
+		// served without Content-Encoding
+		PackagesArchive: gzipBytes(t, "# AUR package list\nyay\nyay-bin\n\n"),
+		PkgBaseArchive:  []byte("yay\nlinux-git\n"),
+		UsersArchive:    []byte("jguer\n"),
+	}}
+
+	client, err := New(WithCacheFilePath(t.TempDir()+"/cache.json"), WithHTTPClient(mock))
+	require.NoError(t, err)
+
+	names, err := client.PackageNames(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"yay", "yay-bin"}, names)
+
+	bases, err := client.PackageBases(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"yay", "linux-git"}, bases)
+
+	users, err := client.Users(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"jguer"}, users)
+
+	// cached
+	_, err = client.PackageNames(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{PackagesArchive, PkgBaseArchive, UsersArchive}, mock.requests)
+	assert.NoFileExists(t, client.cacheFilePath)
+}
+
+func TestClientNameListsPolicies(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	cacheFilePath := t.TempDir() + "/cache.json"
+
+	mock := &archiveMockHTTP{archives: map[string][]byte{PackagesArchive: []byte("yay\n")}}
+
+	offline, err := New(WithCacheFilePath(cacheFilePath), WithHTTPClient(mock), WithOffline())
+	require.NoError(t, err)
+
+	_, err = offline.PackageNames(ctx)
+	assert.ErrorIs(t, err, ErrOffline)
+
+	client, err := New(WithCacheFilePath(cacheFilePath), WithHTTPClient(mock))
+	require.NoError(t, err)
+
+	_, err = client.PackageNames(ctx)
+	require.NoError(t, err)
+
+	old := time.Now().Add(-2 * cacheValidity)
+	require.NoError(t, os.Chtimes(client.archiveCachePath(PackagesArchive), old, old))
+
+	names, err := offline.PackageNames(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"yay"}, names)
+
+	delete(mock.archives, PackagesArchive)
+
+	_, err = client.PackageNames(ctx)
+	assert.ErrorContains(t, err, "404")
+
+	stale, err := New(WithCacheFilePath(cacheFilePath), WithHTTPClient(mock), WithStaleFallback())
+	require.NoError(t, err)
+
+	names, err = stale.PackageNames(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"yay"}, names)
+}
+
+func TestClientLightMetadata(t *testing.T) {
+	t.Parallel()
+
+	testBytes, err := os.ReadFile("test.json")
+	require.NoError(t, err)
+
+	mock := &archiveMockHTTP{archives: map[string][]byte{MetaArchive: gzipBytes(t, string(testBytes))}}
+
+	cacheFilePath := t.TempDir() + "/cache.json"
+
+	full, err := New(WithCacheFilePath(cacheFilePath), WithHTTPClient(&MockHTTP{bytesToReturn: testBytes}))
+	require.NoError(t, err)
+	assert.Equal(t, "11.3.0-1", yayVersion(t, full))
+
+	// the full dump cached at the same path is not served instead
+	client, err := New(WithCacheFilePath(cacheFilePath), WithHTTPClient(mock), WithLightMetadata())
+	require.NoError(t, err)
+
+	assert.Equal(t, "11.3.0-1", yayVersion(t, client))
+	assert.Equal(t, []string{MetaArchive}, mock.requests)
+	assert.Equal(t, cacheFilePath+"-"+MetaArchive, client.cacheFilePath)
+	assert.FileExists(t, client.cacheFilePath)
+}
@@ -1,27 +1,53 @@
 package metadata
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"time"
 
-	"github.com/ohler55/ojg/oj"
+	"github.com/Jguer/aur"
 )
 
-const endpoint = "packages-meta-ext-v1.json.gz"
+// Archives published by aurweb.
+const (
+	// ExtMetaArchive is the metadata of all packages including dependencies,
+	// the default data of a Client.
+	ExtMetaArchive = "packages-meta-ext-v1.json.gz"
+	// MetaArchive is the metadata of all packages without dependency,
+	// license, keyword, group and co-maintainer fields.
+	MetaArchive = "packages-meta-v1.json.gz"
+	// PackagesArchive lists all package names.
+	PackagesArchive = "packages.gz"
+	// PkgBaseArchive lists all package base names.
+	PkgBaseArchive = "pkgbase.gz"
+	// UsersArchive lists all user names.
+	UsersArchive = "users.gz"
+)
 
 type HTTPRequestDoer interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
-// needsUpdate checks if cachepath is older than 24 hours.
+// needsUpdate checks if the cache file is older than the cache validity.
 func (a *Client) needsUpdate() (bool, error) {
-	// check if cache is older than 24 hours
-	info, err := os.Stat(a.cacheFilePath)
+	return a.expired(a.cacheFilePath)
+}
+
+// expired checks if the file at path is missing or older than the cache validity.
+func (a *Client) expired(path string) (bool, error) {
+	info, err := os.Stat(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return true, nil
@@ -33,94 +59,457 @@ func (a *Client) needsUpdate() (bool, error) {
 	return info.ModTime().Before(time.Now().Add(-a.cacheValidity)), nil
 }
 
-func (a *Client) cache(ctx context.Context) ([]any, error) {
-	if a.unmarshalledCache != nil {
-		return a.unmarshalledCache, nil
+// cache returns the data in use, loading it on first use.
+// Concurrent callers share a single load.
+func (a *Client) cache(ctx context.Context) (*dataset, error) {
+	if ds := a.unmarshalledCache.Load(); ds != nil {
+		return ds, nil
 	}
 
-	update, err := a.needsUpdate()
-	if err != nil {
-		return nil, err
-	}
+	defer a.deliverChanges()
 
-	if update {
-		if a.debugLoggerFn != nil {
-			a.debugLoggerFn("AUR Cache is out of date, updating")
+	return a.flights.do(ctx, "load", func(ctx context.Context) (*dataset, error) {
+		a.updateMu.Lock()
+		defer a.updateMu.Unlock()
+
+		// loaded while waiting for the lock
+		if ds := a.unmarshalledCache.Load(); ds != nil {
+			return ds, nil
 		}
-		cache, makeErr := a.makeCache(ctx)
-		if makeErr != nil {
-			return nil, makeErr
+
+		update, err := a.needsUpdate()
+		if err != nil {
+			return nil, err
+		}
+
+		if update && !a.offline {
+			if a.debugLoggerFn != nil {
+				a.debugLoggerFn("AUR Cache is out of date, updating")
+			}
+
+			ds, errR := a.refresh(ctx)
+			if errR == nil || !a.staleFallback || isContextErr(errR) {
+				return ds, errR
+			}
+
+			return a.loadStale(errR)
 		}
 
-		inputStruct, unmarshallErr := oj.Parse(cache)
-		if unmarshallErr != nil {
-			return nil, fmt.Errorf("aur metadata unable to parse cache: %w", unmarshallErr)
+		ds, err := a.loadCache()
+		if errors.Is(err, ErrCorruptCache) {
+			return a.heal(ctx, err)
 		}
 
-		a.unmarshalledCache = inputStruct.([]any)
-	} else {
-		aurCache, err := readCache(a.cacheFilePath)
 		if err != nil {
+			if a.offline && errors.Is(err, os.ErrNotExist) {
+				return nil, ErrOffline
+			}
+
 			return nil, err
 		}
 
-		inputStruct, err := oj.Parse(aurCache)
-		if err != nil {
-			return nil, fmt.Errorf("aur metadata unable to parse cache: %w", err)
+		a.unmarshalledCache.Store(ds)
+		a.record(ds)
+
+		return ds, nil
+	})
+}
+
+// Refresh downloads the AUR metadata regardless of the cache validity and
+// replaces the data in use. Registered change handlers are called with the
+// differences to the previous data. Concurrent calls share a single download.
+func (a *Client) Refresh(ctx context.Context) error {
+	defer a.deliverChanges()
+
+	_, err := a.flights.do(ctx, "refresh", func(ctx context.Context) (*dataset, error) {
+		a.updateMu.Lock()
+		defer a.updateMu.Unlock()
+
+		return a.refresh(ctx)
+	})
+
+	return err
+}
+
+// refresh downloads the metadata and swaps it in. Must hold a.updateMu.
+func (a *Client) refresh(ctx context.Context) (*dataset, error) {
+	if a.static {
+		return nil, ErrNoSource
+	}
+
+	if a.offline {
+		return nil, ErrOffline
+	}
+
+	prev := a.unmarshalledCache.Load()
+	if prev == nil && len(a.changeHandlers) != 0 {
+		// compare against the expired cache of a previous run, if any
+		if _, err := os.Stat(a.cacheFilePath); err == nil {
+			var errL error
+			if prev, errL = a.loadCache(); errL != nil && a.debugLoggerFn != nil {
+				a.debugLoggerFn("AUR metadata unable to load previous cache", errL)
+			}
 		}
+	}
+
+	pkgs, err := a.makeCache(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-		a.unmarshalledCache = inputStruct.([]any)
+	ds := newDataset(pkgs)
+
+	info, errS := os.Stat(a.cacheFilePath)
+	if errS == nil {
+		ds.modTime = info.ModTime()
+	}
+
+	a.unmarshalledCache.Store(ds)
+
+	if errS == nil {
+		a.saveSnapshot(ds, info)
 	}
 
-	return a.unmarshalledCache, nil
+	a.record(ds)
+	a.notifyChanges(prev, ds)
+
+	return ds, nil
 }
 
-func readCache(cachePath string) ([]byte, error) {
-	fp, err := os.Open(cachePath)
+// heal quarantines the corrupt cache file and downloads the metadata again.
+// Must hold a.updateMu.
+func (a *Client) heal(ctx context.Context, corruptErr error) (*dataset, error) {
+	a.quarantine(corruptErr)
+
+	if a.offline {
+		return nil, corruptErr
+	}
+
+	return a.refresh(ctx)
+}
+
+// loadStale serves the expired cache file after refreshing failed with
+// refreshErr. Must hold a.updateMu.
+func (a *Client) loadStale(refreshErr error) (*dataset, error) {
+	if _, err := os.Stat(a.cacheFilePath); err != nil {
+		return nil, refreshErr
+	}
+
+	ds, err := a.loadCache()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
+		return nil, refreshErr
+	}
+
+	if a.debugLoggerFn != nil {
+		a.debugLoggerFn("AUR metadata refresh failed, using stale cache", refreshErr)
+	}
+
+	ds.refreshErr = refreshErr
+	a.unmarshalledCache.Store(ds)
+	// recorders ignore it if they already saw this cache file
+	a.record(ds)
+
+	return ds, nil
+}
+
+// loadCache loads the cache file, from its snapshot when possible.
+// The cache file is verified against its sum, failures wrap ErrCorruptCache.
+func (a *Client) loadCache() (*dataset, error) {
+	// taken first so a concurrent update of the file is noticed later on,
+	// and isn't mistaken for the source of the snapshot
+	info, err := os.Stat(a.cacheFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.snapshot {
+		ds, errS := a.loadSnapshot(info)
+		if errS == nil {
+			ds.modTime = info.ModTime()
+
+			return ds, nil
 		}
 
+		if a.debugLoggerFn != nil {
+			a.debugLoggerFn("AUR metadata snapshot not usable", errS)
+		}
+	}
+
+	sum, verified, err := a.verifyCache()
+	if err != nil {
+		return nil, err
+	}
+
+	pr := a.newProgress(PhaseParse)
+
+	// the file is hashed while it is decoded
+	h := sha256.New()
+
+	aurCache, err := openCache(a.cacheFilePath, pr, h)
+	if err != nil {
+		return nil, err
+	}
+	defer aurCache.Close()
+
+	pkgs, err := DecodePkgs(aurCache, a.fields...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to parse cache: %v", ErrCorruptCache, err)
+	}
+
+	// reaching the end checks the gzip checksum
+	if _, errC := io.Copy(io.Discard, aurCache); errC != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptCache, errC)
+	}
+
+	if verified && hex.EncodeToString(h.Sum(nil)) != sum.SHA256 {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorruptCache)
+	}
+
+	if verified && len(pkgs) != sum.Packages {
+		return nil, fmt.Errorf("%w: %d packages instead of %d", ErrCorruptCache, len(pkgs), sum.Packages)
+	}
+
+	pr.done()
+
+	ds := newDataset(pkgs)
+	ds.modTime = info.ModTime()
+	a.saveSnapshot(ds, info)
+
+	return ds, nil
+}
+
+// cacheModTime returns the modification time of the cache file,
+// or the zero time if it can't be read.
+func (a *Client) cacheModTime() time.Time {
+	info, err := os.Stat(a.cacheFilePath)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
+
+// saveSnapshot writes the binary snapshot of the cache file described by
+// info if enabled. A snapshot is only an optimisation so failures are logged
+// and ignored.
+func (a *Client) saveSnapshot(ds *dataset, info os.FileInfo) {
+	if !a.snapshot {
+		return
+	}
+
+	if err := a.writeSnapshot(ds, info); err != nil && a.debugLoggerFn != nil {
+		a.debugLoggerFn("AUR metadata unable to write snapshot", err)
+	}
+}
+
+// gzipMagic is the header every gzip stream starts with. Cache files written
+// before compression was introduced are plain JSON and never start with it.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+type gzipReadCloser struct {
+	*gzip.Reader
+	closer io.Closer
+}
+
+func (g gzipReadCloser) Close() error {
+	g.Reader.Close()
+
+	return g.closer.Close()
+}
+
+// openCache returns a reader over the decompressed cache at cachePath,
+// reporting the bytes read to pr and writing them to h if not nil.
+// Uncompressed caches left by older versions are transparently migrated.
+func openCache(cachePath string, pr *progress, h io.Writer) (io.ReadCloser, error) {
+	fp, err := os.Open(cachePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader = fp
+	if h != nil {
+		r = io.TeeReader(fp, h)
+	}
+
+	br := bufio.NewReader(pr.reader(r, 0, sizeOf(fp)))
+
+	magic, err := br.Peek(len(gzipMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		fp.Close()
+
 		return nil, err
 	}
 
+	if !bytes.Equal(magic, gzipMagic) {
+		fp.Close()
+
+		if errM := migrateCache(cachePath); errM != nil {
+			return nil, errM
+		}
+
+		return openCache(cachePath, pr, h)
+	}
+
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		fp.Close()
+
+		return nil, fmt.Errorf("unable to decompress cache: %w", err)
+	}
+
+	return gzipReadCloser{Reader: zr, closer: fp}, nil
+}
+
+// migrateCache rewrites an uncompressed cache in compressed form while
+// keeping its modification time, so the migration does not extend its validity.
+func migrateCache(cachePath string) error {
+	fp, err := os.Open(cachePath)
+	if err != nil {
+		return err
+	}
 	defer fp.Close()
 
-	s, err := io.ReadAll(fp)
+	info, err := fp.Stat()
 	if err != nil {
+		return err
+	}
+
+	if err := writeCache(cachePath, fp); err != nil {
+		return err
+	}
+
+	return os.Chtimes(cachePath, info.ModTime(), info.ModTime())
+}
+
+// cacheWriter compresses everything written to it into a temporary file
+// which replaces the cache atomically on Commit, so readers never observe
+// a partial cache.
+type cacheWriter struct {
+	f    *os.File
+	zw   *gzip.Writer
+	h    hash.Hash
+	size int64
+	path string
+}
+
+func newCacheWriter(cachePath string) (*cacheWriter, error) {
+	f, err := os.CreateTemp(filepath.Dir(cachePath), filepath.Base(cachePath)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+
+	zw, err := gzip.NewWriterLevel(io.MultiWriter(f, h), gzip.BestSpeed)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+
 		return nil, err
 	}
 
-	return s, nil
+	return &cacheWriter{f: f, zw: zw, h: h, path: cachePath}, nil
 }
 
-// Download the metadata for aur packages.
-// create cache file
-// write to cache file.
-func (a *Client) makeCache(ctx context.Context) ([]byte, error) {
-	body, err := a.downloadAURMetadata(ctx)
+func (w *cacheWriter) Write(p []byte) (int, error) {
+	return w.zw.Write(p)
+}
+
+// Commit flushes the compressed stream and moves it into place.
+func (w *cacheWriter) Commit() error {
+	if err := w.zw.Close(); err != nil {
+		return err
+	}
+
+	info, err := w.f.Stat()
+	if err != nil {
+		return err
+	}
+
+	w.size = info.Size()
+
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(w.f.Name(), w.path)
+}
+
+// sum describes the committed file holding pkgs packages.
+func (w *cacheWriter) sum(pkgs int) cacheSum {
+	return cacheSum{SHA256: hex.EncodeToString(w.h.Sum(nil)), Size: w.size, Packages: pkgs}
+}
+
+// Abort discards the temporary file. It is a no-op after a successful Commit.
+func (w *cacheWriter) Abort() {
+	w.f.Close()
+	os.Remove(w.f.Name())
+}
+
+// writeCache stores the contents of r gzip compressed at cachePath.
+func writeCache(cachePath string, r io.Reader) error {
+	w, err := newCacheWriter(cachePath)
+	if err != nil {
+		return err
+	}
+	defer w.Abort()
+
+	if _, err := io.Copy(w, r); err != nil {
+		return err
+	}
+
+	return w.Commit()
+}
+
+// makeCache downloads the metadata into the cache file.
+// Metadata from a custom source is decoded while it is written compressed to
+// the cache file.
+func (a *Client) makeCache(ctx context.Context) ([]aur.Pkg, error) {
+	if a.source == nil {
+		return a.downloadCache(ctx)
+	}
+
+	pr := a.newProgress(PhaseDownload)
+
+	body, err := a.openSource(ctx, pr)
 	if err != nil {
 		return nil, err
 	}
 	defer body.Close()
 
-	s, err := io.ReadAll(body)
+	br := bufio.NewReader(body)
+	if errC := checkDownload(br); errC != nil {
+		return nil, errC
+	}
+
+	w, err := newCacheWriter(a.cacheFilePath)
 	if err != nil {
 		return nil, err
 	}
+	defer w.Abort()
 
-	f, err := os.Create(a.cacheFilePath)
+	pkgs, err := DecodePkgs(io.TeeReader(br, w), a.fields...)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: unable to parse: %v", ErrInvalidDownload, err)
+	}
+
+	if errC := a.checkPackageCount(len(pkgs)); errC != nil {
+		return nil, errC
 	}
-	defer f.Close()
 
-	if _, err = f.Write(s); err != nil {
+	// the decoder may stop before the end of the body, keep the trailing bytes
+	if _, err := io.Copy(w, br); err != nil {
 		return nil, err
 	}
 
-	return s, err
+	sumFn := func() (cacheSum, error) { return w.sum(len(pkgs)), nil }
+	if errR := a.replaceCache(w.Commit, sumFn); errR != nil {
+		return nil, errR
+	}
+
+	pr.done()
+
+	return pkgs, nil
 }
 
 func (a *Client) applyEditors(ctx context.Context, req *http.Request) error {
@@ -133,8 +522,50 @@ func (a *Client) applyEditors(ctx context.Context, req *http.Request) error {
 	return nil
 }
 
-func (a *Client) downloadAURMetadata(ctx context.Context) (io.ReadCloser, error) {
-	reqURL, err := url.JoinPath(a.baseURL, endpoint)
+// openSource returns the decompressed metadata from the custom source,
+// reporting the bytes received to pr if not nil.
+func (a *Client) openSource(ctx context.Context, pr *progress) (io.ReadCloser, error) {
+	body, err := a.source(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return maybeGunzip(readCloser{Reader: pr.reader(body, 0, sizeOf(body)), Closer: body})
+}
+
+// downloadArchive returns the decompressed contents of an aurweb archive.
+func (a *Client) downloadArchive(ctx context.Context, archive string) (io.ReadCloser, error) {
+	resp, err := a.fetchArchive(ctx, archive)
+	if err != nil {
+		return nil, err
+	}
+
+	return maybeGunzip(resp.Body)
+}
+
+// fetchArchive requests an aurweb archive, failing on any status but 200.
+func (a *Client) fetchArchive(ctx context.Context, archive string) (*http.Response, error) {
+	req, err := a.archiveRequest(ctx, archive)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+
+		return nil, &statusError{code: resp.StatusCode, status: resp.Status}
+	}
+
+	return resp, nil
+}
+
+func (a *Client) archiveRequest(ctx context.Context, archive string) (*http.Request, error) {
+	reqURL, err := url.JoinPath(a.baseURL, archive)
 	if err != nil {
 		return nil, err
 	}
@@ -148,14 +579,37 @@ func (a *Client) downloadAURMetadata(ctx context.Context) (io.ReadCloser, error)
 		return nil, errE
 	}
 
-	resp, err := a.httpClient.Do(req)
-	if err != nil {
+	return req, nil
+}
+
+// maybeGunzip decompresses r if it is gzip compressed. Archives are normally
+// served with a gzip Content-Encoding and decompressed by the transport, but
+// not every server or HTTPRequestDoer does so.
+func maybeGunzip(r io.ReadCloser) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(len(gzipMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		r.Close()
+
 		return nil, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to download metadata: %s", resp.Status)
+	if !bytes.Equal(magic, gzipMagic) {
+		return readCloser{Reader: br, Closer: r}, nil
 	}
 
-	return resp.Body, nil
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		r.Close()
+
+		return nil, fmt.Errorf("unable to decompress download: %w", err)
+	}
+
+	return gzipReadCloser{Reader: zr, closer: r}, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
 }
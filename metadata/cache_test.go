@@ -107,17 +107,29 @@ func TestClientMakeCache(t *testing.T) {
 	ctx := context.Background()
 
 	// cache file does not exist
-	byNew, err := client.makeCache(ctx)
+	pkgsNew, err := client.makeCache(ctx)
 	require.NoError(t, err)
 
-	assert.Equal(t, testBytes, byNew)
+	assert.Len(t, pkgsNew, 12)
 
-	readCache, err := readCache(cacheFilePath)
-	require.NoError(t, err)
+	readCache := readCacheBytes(t, cacheFilePath)
 
 	assert.Equal(t, testBytes, readCache)
 }
 
+func readCacheBytes(t *testing.T, cacheFilePath string) []byte {
+	t.Helper()
+
+	r, err := openCache(cacheFilePath, nil, nil)
+	require.NoError(t, err)
+	defer r.Close()
+
+	s, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	return s
+}
+
 func TestClientCacheAccess(t *testing.T) {
 	t.Parallel()
 	dir := t.TempDir()
@@ -149,19 +161,72 @@ func TestClientCacheAccess(t *testing.T) {
 	require.NoError(t, err)
 
 	assert.NotNil(t, cache)
-	assert.Equal(t, cache, client.unmarshalledCache)
+	assert.Equal(t, cache, client.unmarshalledCache.Load())
 	assert.Equal(t, 1, len(logged))
 
 	// cache is in memory
 	cache, err = client.cache(ctx)
 	require.NoError(t, err)
-	assert.Equal(t, cache, client.unmarshalledCache)
+	assert.Equal(t, cache, client.unmarshalledCache.Load())
 	assert.Equal(t, 1, len(logged))
 
 	// cache file exists
-	client.unmarshalledCache = nil
+	client.unmarshalledCache.Store(nil)
 	cache, err = client.cache(ctx)
 	require.NoError(t, err)
-	assert.Equal(t, cache, client.unmarshalledCache)
+	assert.Equal(t, cache, client.unmarshalledCache.Load())
 	assert.Equal(t, 1, len(logged))
 }
+
+func TestClientMakeCacheCompressed(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	cacheFilePath := dir + "/cache.json"
+
+	testBytes, err := os.ReadFile("test.json")
+	require.NoError(t, err)
+
+	client, err := New(
+		WithCacheFilePath(cacheFilePath),
+		WithHTTPClient(&MockHTTP{bytesToReturn: testBytes}),
+	)
+	require.NoError(t, err)
+
+	_, err = client.makeCache(context.Background())
+	require.NoError(t, err)
+
+	onDisk, err := os.ReadFile(cacheFilePath)
+	require.NoError(t, err)
+
+	assert.Equal(t, gzipMagic, onDisk[:2])
+	assert.Less(t, len(onDisk), len(testBytes))
+}
+
+func TestOpenCacheMigratesUncompressed(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	cacheFilePath := dir + "/cache.json"
+
+	testBytes, err := os.ReadFile("test.json")
+	require.NoError(t, err)
+
+	// cache written by a previous version
+	require.NoError(t, os.WriteFile(cacheFilePath, testBytes, 0o600))
+	modTime := time.Now().Add(-cacheValidity / 2).Truncate(time.Second)
+	require.NoError(t, os.Chtimes(cacheFilePath, modTime, modTime))
+
+	got := readCacheBytes(t, cacheFilePath)
+	assert.Equal(t, testBytes, got)
+
+	onDisk, err := os.ReadFile(cacheFilePath)
+	require.NoError(t, err)
+	assert.Equal(t, gzipMagic, onDisk[:2])
+
+	info, err := os.Stat(cacheFilePath)
+	require.NoError(t, err)
+	assert.True(t, modTime.Equal(info.ModTime()))
+
+	// migrated cache reads back the same
+	got = readCacheBytes(t, cacheFilePath)
+	assert.Equal(t, testBytes, got)
+}
@@ -0,0 +1,191 @@
+package metadata
+
+import (
+	"fmt"
+
+	"github.com/Jguer/aur"
+)
+
+// ChangeKind specifies what changed about a package between two snapshots.
+type ChangeKind int
+
+const (
+	PkgAdded ChangeKind = iota + 1
+	PkgRemoved
+	VersionChanged
+	MaintainerChanged
+	PkgOrphaned
+	PkgFlagged
+	PkgUnflagged
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case PkgAdded:
+		return "added"
+	case PkgRemoved:
+		return "removed"
+	case VersionChanged:
+		return "version"
+	case MaintainerChanged:
+		return "maintainer"
+	case PkgOrphaned:
+		return "orphaned"
+	case PkgFlagged:
+		return "flagged"
+	case PkgUnflagged:
+		return "unflagged"
+	default:
+		panic("invalid ChangeKind")
+	}
+}
+
+// MarshalText makes change kinds readable in JSON audit logs.
+func (k ChangeKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *ChangeKind) UnmarshalText(text []byte) error {
+	for c := PkgAdded; c <= PkgUnflagged; c++ {
+		if c.String() == string(text) {
+			*k = c
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid change kind %q", text)
+}
+
+// Change is a single difference between two metadata snapshots.
+type Change struct {
+	Kind ChangeKind `json:"Kind"`
+	Name string     `json:"Name"`
+	// Old and New hold the changed value, e.g. the versions for VersionChanged.
+	// They are empty for PkgAdded and PkgRemoved.
+	Old string `json:"Old,omitempty"`
+	New string `json:"New,omitempty"`
+	// Pkg is the package as found in the new snapshot,
+	// or in the old one for PkgRemoved.
+	Pkg aur.Pkg `json:"Pkg"`
+}
+
+// ChangeFn is called with the changes found by a metadata refresh.
+type ChangeFn func(changes []Change)
+
+// Diff compares two metadata snapshots. Changes are reported in the order of
+// newPkgs, followed by the removed packages in the order of oldPkgs.
+//
+// A package becoming orphaned is reported as PkgOrphaned rather than
+// MaintainerChanged, adopting an orphan is a MaintainerChanged.
+func Diff(oldPkgs, newPkgs []aur.Pkg) []Change {
+	return diffDatasets(newDataset(oldPkgs), newDataset(newPkgs))
+}
+
+func diffDatasets(oldDS, newDS *dataset) []Change {
+	changes := []Change{}
+
+	for i := range newDS.Pkgs {
+		pkg := &newDS.Pkgs[i]
+		if newDS.ByName[pkg.Name] != i {
+			continue // duplicate
+		}
+
+		old := oldDS.lookup(pkg.Name)
+		if old == nil {
+			changes = append(changes, Change{Kind: PkgAdded, Name: pkg.Name, Pkg: *pkg})
+
+			continue
+		}
+
+		changes = appendPkgChanges(changes, old, pkg)
+	}
+
+	for i := range oldDS.Pkgs {
+		pkg := &oldDS.Pkgs[i]
+		if oldDS.ByName[pkg.Name] == i && newDS.lookup(pkg.Name) == nil {
+			changes = append(changes, Change{Kind: PkgRemoved, Name: pkg.Name, Pkg: *pkg})
+		}
+	}
+
+	return changes
+}
+
+func appendPkgChanges(changes []Change, old, pkg *aur.Pkg) []Change {
+	change := func(kind ChangeKind, oldValue, newValue string) Change {
+		return Change{Kind: kind, Name: pkg.Name, Old: oldValue, New: newValue, Pkg: *pkg}
+	}
+
+	if old.Version != pkg.Version {
+		changes = append(changes, change(VersionChanged, old.Version, pkg.Version))
+	}
+
+	if old.Maintainer != pkg.Maintainer {
+		if pkg.Maintainer == "" {
+			changes = append(changes, change(PkgOrphaned, old.Maintainer, ""))
+		} else {
+			changes = append(changes, change(MaintainerChanged, old.Maintainer, pkg.Maintainer))
+		}
+	}
+
+	switch {
+	case old.OutOfDate == 0 && pkg.OutOfDate != 0:
+		changes = append(changes, change(PkgFlagged, "", fmt.Sprint(pkg.OutOfDate)))
+	case old.OutOfDate != 0 && pkg.OutOfDate == 0:
+		changes = append(changes, change(PkgUnflagged, fmt.Sprint(old.OutOfDate), ""))
+	}
+
+	return changes
+}
+
+// notifyChanges queues the differences between two loaded datasets for the
+// change handlers. Must hold a.updateMu, deliverChanges calls the handlers
+// once it is released.
+func (a *Client) notifyChanges(oldDS, newDS *dataset) {
+	if oldDS == nil || len(a.changeHandlers) == 0 {
+		return
+	}
+
+	changes := diffDatasets(oldDS, newDS)
+
+	if a.debugLoggerFn != nil {
+		a.debugLoggerFn("AUR metadata changes", len(changes))
+	}
+
+	if len(changes) == 0 {
+		return
+	}
+
+	a.changesMu.Lock()
+	a.pendingChanges = append(a.pendingChanges, changes)
+	a.changesMu.Unlock()
+}
+
+// deliverChanges calls the change handlers with the queued changes, in
+// order. Changes queued while handlers run, such as by a handler refreshing
+// the client, are delivered by the call already running.
+func (a *Client) deliverChanges() {
+	a.changesMu.Lock()
+	if a.delivering {
+		a.changesMu.Unlock()
+
+		return
+	}
+
+	a.delivering = true
+
+	for len(a.pendingChanges) != 0 {
+		changes := a.pendingChanges[0]
+		a.pendingChanges = a.pendingChanges[1:]
+		a.changesMu.Unlock()
+
+		for _, fn := range a.changeHandlers {
+			fn(changes)
+		}
+
+		a.changesMu.Lock()
+	}
+
+	a.delivering = false
+	a.changesMu.Unlock()
+}
@@ -0,0 +1,202 @@
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Jguer/aur"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	oldPkgs := []aur.Pkg{
+		{Name: "same", Version: "1", Maintainer: "a"},
+		{Name: "bumped", Version: "1", Maintainer: "a"},
+		{Name: "adopted", Version: "1"},
+		{Name: "orphaned", Version: "1", Maintainer: "a"},
+		{Name: "flagged", Version: "1", Maintainer: "a"},
+		{Name: "unflagged", Version: "1", Maintainer: "a", OutOfDate: 10},
+		{Name: "removed", Version: "1", Maintainer: "a"},
+	}
+
+	newPkgs := []aur.Pkg{
+		{Name: "added", Version: "1", Maintainer: "b"},
+		{Name: "same", Version: "1", Maintainer: "a"},
+		{Name: "bumped", Version: "2", Maintainer: "b"},
+		{Name: "adopted", Version: "1", Maintainer: "b"},
+		{Name: "orphaned", Version: "1"},
+		{Name: "flagged", Version: "1", Maintainer: "a", OutOfDate: 20},
+		{Name: "unflagged", Version: "1", Maintainer: "a"},
+	}
+
+	type kindName struct {
+		Kind     ChangeKind
+		Name     string
+		Old, New string
+	}
+
+	got := []kindName{}
+	for _, c := range Diff(oldPkgs, newPkgs) {
+		got = append(got, kindName{c.Kind, c.Name, c.Old, c.New})
+	}
+
+	assert.Equal(t, []kindName{
+		{PkgAdded, "added", "", ""},
+		{VersionChanged, "bumped", "1", "2"},
+		{MaintainerChanged, "bumped", "a", "b"},
+		{MaintainerChanged, "adopted", "", "b"},
+		{PkgOrphaned, "orphaned", "a", ""},
+		{PkgFlagged, "flagged", "", "20"},
+		{PkgUnflagged, "unflagged", "10", ""},
+		{PkgRemoved, "removed", "", ""},
+	}, got)
+
+	assert.Empty(t, Diff(oldPkgs, oldPkgs))
+}
+
+func TestChangeJSON(t *testing.T) {
+	t.Parallel()
+
+	b, err := json.Marshal(Change{Kind: PkgOrphaned, Name: "a", Old: "b"})
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"Kind":"orphaned"`)
+
+	var c Change
+	require.NoError(t, json.Unmarshal(b, &c))
+	assert.Equal(t, PkgOrphaned, c.Kind)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"Kind":"nope"}`), &c))
+	assert.Panics(t, func() { _ = ChangeKind(0).String() })
+}
+
+func TestClientChangeHandler(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	cacheFilePath := dir + "/cache.json"
+	ctx := context.Background()
+
+	testBytes, err := os.ReadFile("test.json")
+	require.NoError(t, err)
+
+	pkgs, err := DecodePkgs(bytes.NewReader(testBytes))
+	require.NoError(t, err)
+
+	pkgs[3].Version = "12.0.0-1" // yay
+	pkgs = pkgs[1:]              // liquidsfz-git removed
+
+	updatedBytes, err := json.Marshal(pkgs)
+	require.NoError(t, err)
+
+	mock := &MockHTTP{bytesToReturn: testBytes}
+	calls := [][]Change{}
+
+	client, err := New(
+		WithCacheFilePath(cacheFilePath),
+		WithHTTPClient(mock),
+		WithChangeHandler(func(changes []Change) { calls = append(calls, changes) }),
+	)
+	require.NoError(t, err)
+
+	// initial download has nothing to compare against
+	_, err = client.cache(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, calls)
+
+	// unchanged data
+	require.NoError(t, client.Refresh(ctx))
+	assert.Empty(t, calls)
+
+	mock.bytesToReturn = updatedBytes
+	require.NoError(t, client.Refresh(ctx))
+	require.Len(t, calls, 1)
+	require.Len(t, calls[0], 2)
+	assert.Equal(t, VersionChanged, calls[0][0].Kind)
+	assert.Equal(t, "yay", calls[0][0].Name)
+	assert.Equal(t, PkgRemoved, calls[0][1].Kind)
+	assert.Equal(t, "liquidsfz-git", calls[0][1].Name)
+
+	got, err := client.Get(ctx, &aur.Query{By: aur.Name, Needles: []string{"yay"}})
+	require.NoError(t, err)
+	assert.Equal(t, "12.0.0-1", got[0].Version)
+
+	// a new client compares against the expired cache on disk
+	mock.bytesToReturn = testBytes
+	client, err = New(
+		WithCacheFilePath(cacheFilePath),
+		WithHTTPClient(mock),
+		WithChangeHandler(func(changes []Change) { calls = append(calls, changes) }),
+	)
+	require.NoError(t, err)
+	require.NoError(t, client.Refresh(ctx))
+	require.Len(t, calls, 2)
+	assert.Len(t, calls[1], 2)
+}
+
+func TestClientChangeHandlerRefreshes(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	testBytes, err := os.ReadFile("test.json")
+	require.NoError(t, err)
+
+	pkgs, err := DecodePkgs(bytes.NewReader(testBytes))
+	require.NoError(t, err)
+
+	updatedBytes, err := json.Marshal(pkgs[1:])
+	require.NoError(t, err)
+
+	mock := &MockHTTP{bytesToReturn: testBytes}
+	calls := [][]Change{}
+
+	var client *Client
+
+	client, err = New(
+		WithCacheFilePath(t.TempDir()+"/cache.json"),
+		WithHTTPClient(mock),
+		WithChangeHandler(func(changes []Change) {
+			calls = append(calls, changes)
+			if len(calls) > 1 {
+				return
+			}
+
+			// the client is usable from handlers
+			_, errG := client.Get(ctx, &aur.Query{By: aur.Name, Needles: []string{"yay"}})
+			assert.NoError(t, errG)
+
+			mock.bytesToReturn = testBytes
+			assert.NoError(t, client.Refresh(ctx))
+			// delivered once this handler returns
+			assert.Len(t, calls, 1)
+		}),
+	)
+	require.NoError(t, err)
+
+	_, err = client.cache(ctx)
+	require.NoError(t, err)
+
+	mock.bytesToReturn = updatedBytes
+
+	done := make(chan error)
+	go func() { done <- client.Refresh(ctx) }()
+
+	select {
+	case err = <-done:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("change handler calling Refresh deadlocked")
+	}
+
+	require.Len(t, calls, 2)
+	require.Len(t, calls[0], 1)
+	assert.Equal(t, PkgRemoved, calls[0][0].Kind)
+	require.Len(t, calls[1], 1)
+	assert.Equal(t, PkgAdded, calls[1][0].Kind)
+	assert.Equal(t, "liquidsfz-git", calls[1][0].Name)
+}
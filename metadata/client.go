@@ -5,9 +5,12 @@ import (
 	"net/http"
 	"os"
 	"path"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Jguer/aur"
+	"github.com/itchyny/gojq"
 )
 
 const (
@@ -15,15 +18,55 @@ const (
 	baseURL       = "https://aur.archlinux.org"
 )
 
+// Client queries the AUR metadata dump. It is safe for concurrent use.
 type Client struct {
 	baseURL        string
+	endpoint       string
 	cacheValidity  time.Duration
 	requestEditors []aur.RequestEditorFn
 	httpClient     HTTPRequestDoer
 	cacheFilePath  string
-	debugLoggerFn  LogFn
+	// cacheBasePath is the cache file path as configured, the name list
+	// archives are cached next to it.
+	cacheBasePath   string
+	debugLoggerFn   LogFn
+	fields          []string
+	snapshot        bool
+	offline         bool
+	staleFallback   bool
+	changeHandlers  []ChangeFn
+	refreshJitter   time.Duration
+	refreshErrorFn  RefreshErrorFn
+	source          SourceFn
+	minPackages     int
+	maxShrink       float64
+	progressFn      ProgressFn
+	downloadRetries int
+	retryBackoff    time.Duration
+	recorders       []recorder
+	// static is set for clients holding data that can't be refreshed.
+	static bool
 
-	unmarshalledCache []any
+	refresherMu sync.Mutex
+	refresher   *refresher
+
+	// updateMu serializes replacing the data, flights deduplicates
+	// concurrent loads and refreshes.
+	updateMu sync.Mutex
+	flights  flightGroup
+
+	// changesMu guards the changes waiting for the change handlers, which
+	// are called once updateMu is released.
+	changesMu      sync.Mutex
+	pendingChanges [][]Change
+	delivering     bool
+
+	compiledMu sync.Mutex
+	compiled   map[string]*gojq.Code
+
+	// unmarshalledCache holds the loaded *dataset. It is swapped atomically
+	// so readers never wait for a refresh.
+	unmarshalledCache atomic.Pointer[dataset]
 }
 
 // ClientOption allows setting custom parameters during construction.
@@ -31,14 +74,52 @@ type ClientOption func(*Client) error
 type LogFn func(a ...any)
 
 func New(opts ...ClientOption) (*Client, error) {
+	client, err := newClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if client.cacheFilePath == "" {
+		dir, err := os.MkdirTemp("", "aur-cache-*")
+		if err != nil {
+			return nil, fmt.Errorf("aur cache unable to create temp dir: %w", err)
+		}
+
+		client.cacheFilePath = path.Join(dir, "aur-cache.json")
+	}
+
+	client.setCachePaths()
+
+	return client, nil
+}
+
+// newClient creates a client with opts applied, without a cache file path
+// unless set by opts.
+func newClient(opts ...ClientOption) (*Client, error) {
 	client := &Client{
-		baseURL:           baseURL,
-		cacheValidity:     cacheValidity,
-		requestEditors:    []aur.RequestEditorFn{},
-		httpClient:        nil,
-		cacheFilePath:     "",
-		debugLoggerFn:     nil,
-		unmarshalledCache: nil,
+		baseURL:         baseURL,
+		endpoint:        ExtMetaArchive,
+		cacheValidity:   cacheValidity,
+		requestEditors:  []aur.RequestEditorFn{},
+		httpClient:      nil,
+		cacheFilePath:   "",
+		cacheBasePath:   "",
+		debugLoggerFn:   nil,
+		fields:          nil,
+		snapshot:        false,
+		offline:         false,
+		staleFallback:   false,
+		changeHandlers:  []ChangeFn{},
+		refreshJitter:   -1,
+		refreshErrorFn:  nil,
+		source:          nil,
+		minPackages:     defaultMinPackages,
+		maxShrink:       defaultMaxShrink,
+		progressFn:      nil,
+		downloadRetries: defaultDownloadRetries,
+		retryBackoff:    defaultRetryBackoff,
+		recorders:       []recorder{},
+		compiled:        map[string]*gojq.Code{},
 	}
 
 	// mutate client and add all optional params
@@ -48,21 +129,27 @@ func New(opts ...ClientOption) (*Client, error) {
 		}
 	}
 
+	if client.refreshJitter < 0 {
+		client.refreshJitter = client.cacheValidity / 10
+	}
+
 	// create httpClient, if not already present
 	if client.httpClient == nil {
 		client.httpClient = http.DefaultClient
 	}
 
-	if client.cacheFilePath == "" {
-		dir, err := os.MkdirTemp("", "aur-cache-*")
-		if err != nil {
-			return nil, fmt.Errorf("aur cache unable to create temp dir: %w", err)
-		}
+	return client, nil
+}
 
-		client.cacheFilePath = path.Join(dir, "aur-cache.json")
-	}
+// setCachePaths derives the paths of the cached files from the configured
+// cache file path.
+func (a *Client) setCachePaths() {
+	a.cacheBasePath = a.cacheFilePath
 
-	return client, nil
+	// the dumps hold different fields, so each gets its own cache file
+	if a.endpoint != ExtMetaArchive {
+		a.cacheFilePath = a.archiveCachePath(a.endpoint)
+	}
 }
 
 // WithHTTPClient allows overriding the default Doer, which is
@@ -126,3 +213,153 @@ func WithRequestEditorFn(fn aur.RequestEditorFn) ClientOption {
 		return nil
 	}
 }
+
+// WithFields restricts the package fields kept in memory to the given ones,
+// as spelled in the metadata dump. Name is always kept.
+// Queries on fields that are not kept never match.
+func WithFields(fields ...string) ClientOption {
+	return func(c *Client) error {
+		if _, err := projection(fields); err != nil {
+			return err
+		}
+
+		c.fields = fields
+
+		return nil
+	}
+}
+
+// WithSnapshot enables a binary snapshot of the parsed cache, stored next to
+// the cache file. It is loaded instead of parsing the cache as long as the
+// cache file is unchanged, which makes startup considerably faster.
+func WithSnapshot() ClientOption {
+	return func(c *Client) error {
+		c.snapshot = true
+
+		return nil
+	}
+}
+
+// WithChangeHandler registers a callback which is called with the changes
+// found whenever the metadata is refreshed. Nothing is reported for the
+// initial download when there is no previous data to compare against.
+// Handlers are called in order once the data was replaced, so they may use
+// the client, even to refresh it.
+func WithChangeHandler(fn ChangeFn) ClientOption {
+	return func(c *Client) error {
+		c.changeHandlers = append(c.changeHandlers, fn)
+
+		return nil
+	}
+}
+
+// WithRefreshJitter sets the maximum random delay added to every background
+// refresh. It defaults to a tenth of the cache validity.
+func WithRefreshJitter(jitter time.Duration) ClientOption {
+	return func(c *Client) error {
+		if jitter < 0 {
+			return fmt.Errorf("refresh jitter can't be negative")
+		}
+
+		c.refreshJitter = jitter
+
+		return nil
+	}
+}
+
+// WithRefreshErrorHandler sets a callback for errors of background refreshes.
+func WithRefreshErrorHandler(fn RefreshErrorFn) ClientOption {
+	return func(c *Client) error {
+		c.refreshErrorFn = fn
+
+		return nil
+	}
+}
+
+// WithOffline never downloads metadata, the cache file is used regardless of
+// its age. Loading fails with ErrOffline when there is no cache file.
+func WithOffline() ClientOption {
+	return func(c *Client) error {
+		c.offline = true
+
+		return nil
+	}
+}
+
+// WithStaleFallback serves an expired cache file when downloading fresh
+// metadata fails. DataInfo reports when this happened.
+func WithStaleFallback() ClientOption {
+	return func(c *Client) error {
+		c.staleFallback = true
+
+		return nil
+	}
+}
+
+// WithLightMetadata uses the lighter MetaArchive instead of ExtMetaArchive,
+// for when dependency and other list fields are not needed.
+// It is cached next to the cache file path, named after the archive, so
+// clients using the full metadata can share the same cache file path.
+func WithLightMetadata() ClientOption {
+	return func(c *Client) error {
+		c.endpoint = MetaArchive
+
+		return nil
+	}
+}
+
+// WithMinPackages rejects downloads with fewer than n packages, keeping the
+// previous cache. It guards against truncated or placeholder dumps.
+func WithMinPackages(n int) ClientOption {
+	return func(c *Client) error {
+		if n < 0 {
+			return fmt.Errorf("minimum package count can't be negative")
+		}
+
+		c.minPackages = n
+
+		return nil
+	}
+}
+
+// WithMaxShrink rejects downloads holding fewer packages than the current
+// cache by more than fraction, 0.5 by default, keeping the current cache.
+// It guards against truncated or nearly empty dumps. A fraction of 1
+// accepts any download.
+func WithMaxShrink(fraction float64) ClientOption {
+	return func(c *Client) error {
+		if fraction < 0 || fraction > 1 {
+			return fmt.Errorf("maximum shrink must be between 0 and 1")
+		}
+
+		c.maxShrink = fraction
+
+		return nil
+	}
+}
+
+// WithProgress sets a callback reporting the progress of downloading and
+// parsing the metadata. It is called from the goroutine loading the data.
+func WithProgress(fn ProgressFn) ClientOption {
+	return func(c *Client) error {
+		c.progressFn = fn
+
+		return nil
+	}
+}
+
+// WithDownloadRetries sets how often a failed metadata download is retried,
+// waiting backoff before the first retry and doubling it every time.
+// Retries resume the interrupted download when the server supports it.
+func WithDownloadRetries(retries int, backoff time.Duration) ClientOption {
+	return func(c *Client) error {
+		if retries < 0 || backoff < 0 {
+			return fmt.Errorf("download retries and backoff can't be negative")
+		}
+
+		c.downloadRetries = retries
+		c.retryBackoff = backoff
+
+		return nil
+	}
+}
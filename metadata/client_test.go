@@ -22,7 +22,7 @@ func TestClientCreation(t *testing.T) {
 	assert.Equal(t, http.DefaultClient, client.httpClient)
 	assert.NotEmpty(t, client.cacheFilePath)
 	assert.Nil(t, client.debugLoggerFn)
-	assert.Nil(t, client.unmarshalledCache)
+	assert.Nil(t, client.unmarshalledCache.Load())
 }
 
 func TestClientCreationWithCustomOptions(t *testing.T) {
@@ -48,7 +48,7 @@ func TestClientCreationWithCustomOptions(t *testing.T) {
 	assert.Equal(t, dir+"/cache.json", client.cacheFilePath)
 	assert.NotNil(t, client.debugLoggerFn)
 	assert.NotNil(t, client.requestEditors)
-	assert.Nil(t, client.unmarshalledCache)
+	assert.Nil(t, client.unmarshalledCache.Load())
 }
 
 func TestClientCreationWithInvalidCachePath(t *testing.T) {
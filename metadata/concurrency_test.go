@@ -0,0 +1,129 @@
+package metadata
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jguer/aur"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// These tests are meant to be run with the race detector, see make test-race.
+
+const hammerGoroutines = 64
+
+var hammerQueries = []*aur.Query{
+	{By: aur.Name, Needles: []string{"yay"}},
+	{By: aur.Name, Needles: []string{"jack-audio"}, Contains: true},
+	{By: aur.Provides, Needles: []string{"yay"}},
+	{By: aur.Maintainer, Needles: []string{"jguer"}},
+	{By: aur.NameDesc, Needles: []string{"Pre-compiled"}, Contains: true},
+}
+
+var hammerResults = []int{1, 5, 3, 3, 1}
+
+func newHammerClient(t *testing.T, opts ...ClientOption) (*Client, *syncMockHTTP) {
+	t.Helper()
+
+	testBytes, err := os.ReadFile("test.json")
+	require.NoError(t, err)
+
+	mock := &syncMockHTTP{}
+	mock.set(http.StatusOK, testBytes)
+
+	client, err := New(append([]ClientOption{
+		WithCacheFilePath(t.TempDir() + "/cache.json"),
+		WithHTTPClient(mock),
+	}, opts...)...)
+	require.NoError(t, err)
+
+	return client, mock
+}
+
+func hammer(t *testing.T, client *Client, rounds int) {
+	t.Helper()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < hammerGoroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for r := 0; r < rounds; r++ {
+				q := (i + r) % len(hammerQueries)
+
+				pkgs, err := client.Get(context.Background(), hammerQueries[q])
+				if assert.NoError(t, err) {
+					assert.Len(t, pkgs, hammerResults[q])
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+func TestConcurrentColdGet(t *testing.T) {
+	t.Parallel()
+
+	client, mock := newHammerClient(t)
+
+	hammer(t, client, 1)
+
+	assert.Equal(t, int32(1), mock.calls.Load())
+}
+
+func TestConcurrentColdGetFromDisk(t *testing.T) {
+	t.Parallel()
+
+	client, mock := newHammerClient(t, WithSnapshot())
+	require.NoError(t, client.Refresh(context.Background()))
+
+	client.unmarshalledCache.Store(nil)
+	hammer(t, client, 1)
+
+	assert.Equal(t, int32(1), mock.calls.Load())
+}
+
+func TestConcurrentGetAndRefresh(t *testing.T) {
+	t.Parallel()
+
+	client, mock := newHammerClient(t, WithChangeHandler(func([]Change) {}))
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for r := 0; r < 5; r++ {
+				assert.NoError(t, client.Refresh(context.Background()))
+			}
+		}()
+	}
+
+	hammer(t, client, 20)
+	wg.Wait()
+
+	assert.LessOrEqual(t, mock.calls.Load(), int32(8*5+1))
+}
+
+func TestConcurrentGetWithAutoRefresh(t *testing.T) {
+	t.Parallel()
+
+	client, _ := newHammerClient(t,
+		WithCustomCacheValidity(time.Millisecond),
+		WithRefreshJitter(time.Millisecond))
+
+	require.NoError(t, client.StartAutoRefresh(context.Background()))
+	defer client.StopAutoRefresh()
+
+	hammer(t, client, 20)
+}
@@ -0,0 +1,74 @@
+package metadata
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Jguer/aur"
+)
+
+// dataset is a loaded metadata dump together with its lookup indexes.
+// It is never modified after creation.
+type dataset struct {
+	Pkgs []aur.Pkg
+
+	// ByName maps package names to their index in Pkgs.
+	ByName map[string]int
+
+	// modTime is the modification time of the cache file the data was read
+	// from. It is not part of snapshots.
+	modTime time.Time
+	// refreshErr is the error of the failed refresh that led to serving
+	// this data from an expired cache.
+	refreshErr error
+
+	textOnce sync.Once
+	text     *textIndex
+
+	revDepOnce sync.Once
+	revDeps    map[string][]revDepEdge
+}
+
+func newDataset(pkgs []aur.Pkg) *dataset {
+	ds := &dataset{
+		Pkgs:   pkgs,
+		ByName: make(map[string]int, len(pkgs)),
+	}
+
+	for i := range pkgs {
+		if _, ok := ds.ByName[pkgs[i].Name]; !ok {
+			ds.ByName[pkgs[i].Name] = i
+		}
+	}
+
+	return ds
+}
+
+// lookup returns the package called name, or nil if there is none.
+func (ds *dataset) lookup(name string) *aur.Pkg {
+	i, ok := ds.ByName[name]
+	if !ok {
+		return nil
+	}
+
+	return &ds.Pkgs[i]
+}
+
+// textIndex returns the full text index, building it on first use.
+func (ds *dataset) textIndex() *textIndex {
+	ds.textOnce.Do(func() {
+		ds.text = buildTextIndex(ds)
+	})
+
+	return ds.text
+}
+
+// revDepIndex returns the packages depending on each dependency name,
+// building the index on first use.
+func (ds *dataset) revDepIndex() map[string][]revDepEdge {
+	ds.revDepOnce.Do(func() {
+		ds.revDeps = buildRevDepIndex(ds)
+	})
+
+	return ds.revDeps
+}
@@ -0,0 +1,22 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/Jguer/aur"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatasetLookup(t *testing.T) {
+	t.Parallel()
+
+	ds := newDataset([]aur.Pkg{
+		{Name: "a", Version: "1"},
+		{Name: "b", Version: "1"},
+		{Name: "a", Version: "2"}, // duplicates keep the first entry
+	})
+
+	assert.Equal(t, "1", ds.lookup("a").Version)
+	assert.Equal(t, "b", ds.lookup("b").Name)
+	assert.Nil(t, ds.lookup("c"))
+}
@@ -0,0 +1,187 @@
+package metadata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/Jguer/aur"
+)
+
+// ErrStop can be returned by a StreamPkgs callback to stop decoding early
+// without StreamPkgs reporting an error.
+var ErrStop = errors.New("stop decoding")
+
+// StreamPkgs decodes a metadata dump, a JSON array of packages, from r one
+// element at a time and calls fn for each package. The package passed to fn
+// is reused between calls and must be copied if retained.
+//
+// If fields is not empty only the named fields (as spelled in the dump) are
+// kept, the rest are zeroed before fn is called. Name is always kept.
+func StreamPkgs(r io.Reader, fn func(pkg *aur.Pkg) error, fields ...string) error {
+	keep, err := projection(fields)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(r)
+
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("unable to decode aur metadata: %w", err)
+	}
+
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("unable to decode aur metadata: expected array, got %v", tok)
+	}
+
+	var pkg aur.Pkg
+
+	for dec.More() {
+		pkg = aur.Pkg{}
+		if err := dec.Decode(&pkg); err != nil {
+			return fmt.Errorf("unable to decode aur package: %w", err)
+		}
+
+		if keep != nil {
+			projectPkg(&pkg, keep)
+		}
+
+		if err := fn(&pkg); err != nil {
+			if errors.Is(err, ErrStop) {
+				return nil
+			}
+
+			return err
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("unable to decode aur metadata: %w", err)
+	}
+
+	return nil
+}
+
+// DecodePkgs decodes a metadata dump from r into a slice of packages.
+// See StreamPkgs for the meaning of fields.
+func DecodePkgs(r io.Reader, fields ...string) ([]aur.Pkg, error) {
+	pkgs := make([]aur.Pkg, 0, 1024)
+
+	err := StreamPkgs(r, func(pkg *aur.Pkg) error {
+		pkgs = append(pkgs, *pkg)
+
+		return nil
+	}, fields...)
+	if err != nil {
+		return nil, err
+	}
+
+	return pkgs, nil
+}
+
+// pkgFieldIndex maps the JSON name of every aur.Pkg field to its index.
+var pkgFieldIndex = func() map[string]int {
+	t := reflect.TypeOf(aur.Pkg{})
+	index := make(map[string]int, t.NumField())
+
+	for i := 0; i < t.NumField(); i++ {
+		index[t.Field(i).Tag.Get("json")] = i
+	}
+
+	return index
+}()
+
+// projection validates fields and returns the set of field indexes to keep,
+// or nil if every field should be kept.
+func projection(fields []string) (map[int]bool, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	keep := map[int]bool{pkgFieldIndex["Name"]: true}
+
+	for _, field := range fields {
+		i, ok := pkgFieldIndex[field]
+		if !ok {
+			return nil, fmt.Errorf("unknown package field %q", field)
+		}
+
+		keep[i] = true
+	}
+
+	return keep, nil
+}
+
+func projectPkg(pkg *aur.Pkg, keep map[int]bool) {
+	v := reflect.ValueOf(pkg).Elem()
+
+	for i := 0; i < v.NumField(); i++ {
+		if !keep[i] {
+			f := v.Field(i)
+			f.Set(reflect.Zero(f.Type()))
+		}
+	}
+}
+
+// pkgToMap returns the generic representation of pkg used by gojq, matching
+// the layout of the metadata dump where empty values are null.
+// Only the given field indexes are included, or every field if fields is nil.
+func pkgToMap(pkg *aur.Pkg, fields []int) map[string]any {
+	v := reflect.ValueOf(pkg).Elem()
+	t := v.Type()
+
+	if fields == nil {
+		fields = allPkgFields
+	}
+
+	m := make(map[string]any, len(fields))
+
+	for _, i := range fields {
+		name := t.Field(i).Tag.Get("json")
+		f := v.Field(i)
+
+		switch f.Kind() {
+		case reflect.String:
+			if f.Len() != 0 {
+				m[name] = f.String()
+			} else {
+				m[name] = nil
+			}
+		case reflect.Int:
+			if name == "OutOfDate" && f.Int() == 0 {
+				m[name] = nil
+			} else {
+				m[name] = int(f.Int())
+			}
+		case reflect.Float64:
+			m[name] = f.Float()
+		case reflect.Slice:
+			if f.IsNil() {
+				m[name] = nil
+
+				continue
+			}
+
+			values := make([]any, f.Len())
+			for j := range values {
+				values[j] = f.Index(j).String()
+			}
+
+			m[name] = values
+		}
+	}
+
+	return m
+}
+
+var allPkgFields = func() []int {
+	fields := make([]int, 0, len(pkgFieldIndex))
+	for i := 0; i < len(pkgFieldIndex); i++ {
+		fields = append(fields, i)
+	}
+
+	return fields
+}()
@@ -0,0 +1,130 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Jguer/aur"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodePkgs(t *testing.T) {
+	t.Parallel()
+
+	f, err := os.Open("test.json")
+	require.NoError(t, err)
+	defer f.Close()
+
+	pkgs, err := DecodePkgs(f)
+	require.NoError(t, err)
+	require.Len(t, pkgs, 12)
+
+	assert.Equal(t, "liquidsfz-git", pkgs[0].Name)
+	assert.Equal(t, 0, pkgs[0].OutOfDate) // null
+	assert.Equal(t, []string{"lv2lint"}, pkgs[0].CheckDepends)
+	assert.Equal(t, 1662556251, pkgs[2].OutOfDate)
+	assert.Equal(t, 1855, pkgs[3].NumVotes)
+}
+
+func TestDecodePkgsProjection(t *testing.T) {
+	t.Parallel()
+
+	f, err := os.Open("test.json")
+	require.NoError(t, err)
+	defer f.Close()
+
+	pkgs, err := DecodePkgs(f, "Version", "Provides")
+	require.NoError(t, err)
+	require.Len(t, pkgs, 12)
+
+	assert.Equal(t, aur.Pkg{
+		Name:     "yay-bin",
+		Version:  "11.3.0-1",
+		Provides: []string{"yay"},
+	}, pkgs[4])
+
+	_, err = DecodePkgs(strings.NewReader("[]"), "Nope")
+	assert.ErrorContains(t, err, "unknown package field")
+}
+
+func TestDecodePkgsInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, input := range []string{"", "{}", `[{"Name": 1}]`, `[{"Name": "a"}`} {
+		_, err := DecodePkgs(strings.NewReader(input))
+		assert.Error(t, err, input)
+	}
+
+	pkgs, err := DecodePkgs(strings.NewReader(` [ ] `))
+	require.NoError(t, err)
+	assert.Empty(t, pkgs)
+}
+
+func TestStreamPkgsStop(t *testing.T) {
+	t.Parallel()
+
+	f, err := os.Open("test.json")
+	require.NoError(t, err)
+	defer f.Close()
+
+	names := []string{}
+	err = StreamPkgs(f, func(pkg *aur.Pkg) error {
+		names = append(names, pkg.Name)
+		if len(names) == 2 {
+			return ErrStop
+		}
+
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"liquidsfz-git", "linux-amd-git"}, names)
+
+	errCallback := errors.New("callback")
+	err = StreamPkgs(strings.NewReader(`[{"Name": "a"}]`), func(pkg *aur.Pkg) error {
+		return errCallback
+	})
+	assert.ErrorIs(t, err, errCallback)
+}
+
+func TestPkgToMap(t *testing.T) {
+	t.Parallel()
+
+	m := pkgToMap(&aur.Pkg{Name: "a", NumVotes: 2, Provides: []string{"b"}}, nil)
+
+	assert.Equal(t, "a", m["Name"])
+	assert.Equal(t, 2, m["NumVotes"])
+	assert.Equal(t, []any{"b"}, m["Provides"])
+	assert.Nil(t, m["Maintainer"])
+	assert.Nil(t, m["OutOfDate"])
+	assert.Nil(t, m["Depends"])
+	assert.Len(t, m, len(pkgFieldIndex))
+
+	m = pkgToMap(&aur.Pkg{Name: "a", NumVotes: 2}, []int{pkgFieldIndex["NumVotes"]})
+	assert.Equal(t, map[string]any{"NumVotes": 2}, m)
+}
+
+func TestClientWithFields(t *testing.T) {
+	t.Parallel()
+
+	testBytes, err := os.ReadFile("test.json")
+	require.NoError(t, err)
+
+	client, err := New(
+		WithCacheFilePath(t.TempDir()+"/cache.json"),
+		WithHTTPClient(&MockHTTP{bytesToReturn: testBytes}),
+		WithFields("Maintainer"),
+	)
+	require.NoError(t, err)
+
+	pkgs, err := client.Get(context.Background(), &aur.Query{By: aur.Maintainer, Needles: []string{"jguer"}})
+	require.NoError(t, err)
+	require.Len(t, pkgs, 3)
+	assert.Empty(t, pkgs[0].Version)
+
+	_, err = New(WithFields("Nope"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,259 @@
+package metadata
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Dep is a parsed dependency, provision or version constraint such as
+// "foo", "foo>=1.2" or "foo=1:2.0-1".
+type Dep struct {
+	Name    string
+	Op      string // one of "", "=", "<", "<=", ">", ">="
+	Version string
+}
+
+// ParseDep parses a dependency string. Descriptions of optional
+// dependencies, "foo: for bar support", are dropped.
+func ParseDep(s string) Dep {
+	if i := strings.Index(s, ": "); i >= 0 {
+		s = s[:i]
+	}
+
+	s = strings.TrimSpace(s)
+
+	i := strings.IndexAny(s, "<>=")
+	if i < 0 {
+		return Dep{Name: s}
+	}
+
+	op := s[i : i+1]
+	if i+1 < len(s) && s[i+1] == '=' {
+		op += "="
+	}
+
+	return Dep{Name: s[:i], Op: op, Version: s[i+len(op):]}
+}
+
+func (d Dep) String() string {
+	return d.Name + d.Op + d.Version
+}
+
+// SatisfiedBy reports whether the package or provision name at version
+// fulfils d. Like pacman, an unversioned provision never satisfies a
+// versioned dependency.
+func (d Dep) SatisfiedBy(name, version string) bool {
+	if d.Name != name {
+		return false
+	}
+
+	if d.Op == "" {
+		return true
+	}
+
+	if version == "" {
+		return false
+	}
+
+	cmp := Vercmp(version, d.Version)
+
+	switch d.Op {
+	case "=":
+		// a constraint without pkgrel matches every release
+		if !strings.Contains(d.Version, "-") {
+			cmp = Vercmp(stripRelease(version), d.Version)
+		}
+
+		return cmp == 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	default:
+		return false
+	}
+}
+
+// SatisfiedByPkg reports whether a package called name at version, providing
+// provides, fulfils d either by name or through one of its provisions.
+func (d Dep) SatisfiedByPkg(name, version string, provides []string) bool {
+	if d.SatisfiedBy(name, version) {
+		return true
+	}
+
+	for _, provide := range provides {
+		p := ParseDep(provide)
+		if d.SatisfiedBy(p.Name, p.Version) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func stripRelease(version string) string {
+	if i := strings.LastIndexByte(version, '-'); i >= 0 {
+		return version[:i]
+	}
+
+	return version
+}
+
+// Vercmp compares two package versions like pacman's vercmp, returning -1, 0
+// or 1. Versions have the form [epoch:]version[-release].
+func Vercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	epochA, versionA, releaseA := parseEVR(a)
+	epochB, versionB, releaseB := parseEVR(b)
+
+	if cmp := rpmvercmp(epochA, epochB); cmp != 0 {
+		return cmp
+	}
+
+	if cmp := rpmvercmp(versionA, versionB); cmp != 0 {
+		return cmp
+	}
+
+	if releaseA != "" && releaseB != "" {
+		return rpmvercmp(releaseA, releaseB)
+	}
+
+	return 0
+}
+
+func parseEVR(evr string) (epoch, version, release string) {
+	epoch = "0"
+	version = evr
+
+	if i := strings.IndexFunc(evr, func(r rune) bool { return !unicode.IsDigit(r) }); i > 0 && evr[i] == ':' {
+		epoch = evr[:i]
+		version = evr[i+1:]
+	} else if i == 0 && evr[0] == ':' {
+		version = evr[1:]
+	}
+
+	if i := strings.LastIndexByte(version, '-'); i >= 0 {
+		release = version[i+1:]
+		version = version[:i]
+	}
+
+	return epoch, version, release
+}
+
+func isAlnum(c byte) bool {
+	return isDigit(c) || isAlpha(c)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// rpmvercmp is a port of the version segment comparison of libalpm.
+func rpmvercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	one, two := 0, 0
+
+	for one < len(a) && two < len(b) {
+		start1, start2 := one, two
+
+		for one < len(a) && !isAlnum(a[one]) {
+			one++
+		}
+
+		for two < len(b) && !isAlnum(b[two]) {
+			two++
+		}
+
+		if one >= len(a) || two >= len(b) {
+			break
+		}
+
+		// different separator lengths decide
+		if one-start1 != two-start2 {
+			if one-start1 < two-start2 {
+				return -1
+			}
+
+			return 1
+		}
+
+		end1, end2 := one, two
+		isNum := isDigit(a[one])
+
+		if isNum {
+			for end1 < len(a) && isDigit(a[end1]) {
+				end1++
+			}
+
+			for end2 < len(b) && isDigit(b[end2]) {
+				end2++
+			}
+		} else {
+			for end1 < len(a) && isAlpha(a[end1]) {
+				end1++
+			}
+
+			for end2 < len(b) && isAlpha(b[end2]) {
+				end2++
+			}
+		}
+
+		// segments of different types, numbers are newer
+		if end2 == two {
+			if isNum {
+				return 1
+			}
+
+			return -1
+		}
+
+		seg1, seg2 := a[one:end1], b[two:end2]
+
+		if isNum {
+			seg1 = strings.TrimLeft(seg1, "0")
+			seg2 = strings.TrimLeft(seg2, "0")
+
+			if len(seg1) != len(seg2) {
+				if len(seg1) > len(seg2) {
+					return 1
+				}
+
+				return -1
+			}
+		}
+
+		if cmp := strings.Compare(seg1, seg2); cmp != 0 {
+			return cmp
+		}
+
+		one, two = end1, end2
+	}
+
+	oneDone, twoDone := one >= len(a), two >= len(b)
+	if oneDone && twoDone {
+		return 0
+	}
+
+	// a remaining alpha segment never beats an empty one:
+	// if a is empty and b is not alpha b is newer, if a is alpha b is newer,
+	// otherwise a is newer.
+	if (oneDone && !isAlpha(b[two])) || (!oneDone && isAlpha(a[one])) {
+		return -1
+	}
+
+	return 1
+}
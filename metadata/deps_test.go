@@ -0,0 +1,107 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVercmp(t *testing.T) {
+	t.Parallel()
+
+	// taken from pacman's vercmptest.sh
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.5.0", "1.5.0", 0},
+		{"1.5.1", "1.5.0", 1},
+		{"1.5.1", "1.5", 1},
+		{"1.5.0", "1.5", 1},
+		{"1.5b", "1.5", -1},
+		{"1.5.b", "1.5", 1},
+		{"1.0", "1.0a", 1},
+		{"1.0a", "1.0alpha", -1},
+		{"1.0alpha", "1.0b", -1},
+		{"1.0b", "1.0beta", -1},
+		{"1.0beta", "1.0pre", -1},
+		{"1.0pre", "1.0rc", -1},
+		{"1.0rc", "1.0", -1},
+		{"1.0", "1.0.a", -1},
+		{"1.0.a", "1.0.1", -1},
+		{"1", "1.0", -1},
+		{"1.0", "1.1", -1},
+		{"1.1", "1.1.1", -1},
+		{"1.1.1", "1.2", -1},
+		{"1.2", "2.0", -1},
+		{"2.0", "3.0.0", -1},
+		{"1.5.0-1", "1.5.0-1", 0},
+		{"1.5.0-1", "1.5.0-2", -1},
+		{"1.5.0-1", "1.5.1-1", -1},
+		{"1.5.0-2", "1.5.1-1", -1},
+		{"1.5-1", "1.5", 0},
+		{"1.1-1", "1.1", 0},
+		{"1.1", "1.1-1", 0},
+		{"1.0_a", "1.0.a", 0},
+		{"1..0", "1.0", 1},
+		{"1.0", "1..0", -1},
+		{"1.0.", "1.0", 1},
+		{"0:1.0", "0:1.0", 0},
+		{"0:1.0", "1.0", 0},
+		{"1:1.0", "1.0", 1},
+		{"1:1.0", "2:1.0", -1},
+		{"1:1.0-1", "1.1-1", 1},
+		{"2:1.0", "1:9.0", 1},
+		{"1.0.0001", "1.0.1", 0},
+		{"11.3.0.r0.g6ffa869-1", "11.3.0-1", 1},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Vercmp(tt.a, tt.b), "%s %s", tt.a, tt.b)
+		assert.Equal(t, -tt.want, Vercmp(tt.b, tt.a), "%s %s", tt.b, tt.a)
+	}
+}
+
+func TestParseDep(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, Dep{Name: "foo"}, ParseDep("foo"))
+	assert.Equal(t, Dep{Name: "foo", Op: ">=", Version: "1.2"}, ParseDep("foo>=1.2"))
+	assert.Equal(t, Dep{Name: "foo", Op: "=", Version: "1:2.0-1"}, ParseDep("foo=1:2.0-1"))
+	assert.Equal(t, Dep{Name: "foo", Op: "<", Version: "3"}, ParseDep("foo<3"))
+	assert.Equal(t, Dep{Name: "libjack.so"}, ParseDep("libjack.so: for JACK support"))
+	assert.Equal(t, Dep{Name: "java-runtime", Op: ">", Version: "11"}, ParseDep("java-runtime>11: runtime"))
+	assert.Equal(t, "foo<=1", ParseDep("foo<=1").String())
+}
+
+func TestDepSatisfiedBy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		dep, name, version string
+		want               bool
+	}{
+		{"foo", "foo", "", true},
+		{"foo", "foo", "1.0-1", true},
+		{"foo", "bar", "1.0-1", false},
+		{"foo>=1.2", "foo", "1.2-1", true},
+		{"foo>=1.2", "foo", "1.1-3", false},
+		{"foo>=1.2", "foo", "", false},
+		{"foo<2", "foo", "1.9", true},
+		{"foo<=2", "foo", "2", true},
+		{"foo>2", "foo", "2", false},
+		{"foo=1.2", "foo", "1.2-5", true},
+		{"foo=1.2-1", "foo", "1.2-5", false},
+		{"foo=1.2", "foo", "1.3-1", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ParseDep(tt.dep).SatisfiedBy(tt.name, tt.version),
+			"%s by %s %s", tt.dep, tt.name, tt.version)
+	}
+
+	dep := ParseDep("java-runtime>=17")
+	assert.True(t, dep.SatisfiedByPkg("jdk-bin", "17.0.1-1", []string{"java-runtime=17", "jdk"}))
+	assert.False(t, dep.SatisfiedByPkg("jdk11-bin", "11.0.1-1", []string{"java-runtime=11"}))
+	assert.False(t, dep.SatisfiedByPkg("jre", "17", []string{"java-runtime"}))
+}
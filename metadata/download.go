@@ -0,0 +1,387 @@
+package metadata
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Jguer/aur"
+)
+
+const (
+	defaultDownloadRetries = 3
+	defaultRetryBackoff    = time.Second
+
+	// lockRetryInterval is how often a download waits for another process
+	// holding the download lock.
+	lockRetryInterval = 100 * time.Millisecond
+)
+
+// errRangeMismatch is returned when a server answers a resumed download
+// with another range than requested.
+var errRangeMismatch = errors.New("unexpected content range")
+
+// statusError is returned when a server answers with an unexpected status.
+type statusError struct {
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	return "failed to download metadata: " + e.status
+}
+
+// retryable reports whether a failed download attempt is worth retrying.
+// Client errors are not, except for timeouts and rate limiting.
+func retryable(err error) bool {
+	if isContextErr(err) {
+		return false
+	}
+
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.code >= http.StatusInternalServerError ||
+			statusErr.code == http.StatusRequestTimeout ||
+			statusErr.code == http.StatusTooManyRequests
+	}
+
+	return true
+}
+
+// partPath returns where the metadata is downloaded to before it is
+// promoted to the cache file.
+func (a *Client) partPath() string {
+	return a.cacheFilePath + ".part"
+}
+
+// etagPath returns where the ETag of the partial download is kept.
+func (a *Client) etagPath() string {
+	return a.partPath() + ".etag"
+}
+
+// lockPath returns the file locked while downloading and promoting the
+// partial file, so processes sharing a cache don't mix their writes.
+func (a *Client) lockPath() string {
+	return a.cacheFilePath + ".lock"
+}
+
+// lockDownload waits until no other process downloads to the partial file.
+func (a *Client) lockDownload(ctx context.Context) (*os.File, error) {
+	for {
+		f, ok, err := tryLock(a.lockPath())
+		if err != nil {
+			return nil, fmt.Errorf("unable to lock metadata download: %w", err)
+		}
+
+		if ok {
+			return f, nil
+		}
+
+		timer := time.NewTimer(lockRetryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return nil, fmt.Errorf("metadata download locked by another process (%s): %w", a.lockPath(), ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// downloadCache downloads the metadata archive into a partial file, then
+// verifies it and promotes it to the cache file. Both steps hold the
+// download lock.
+func (a *Client) downloadCache(ctx context.Context) ([]aur.Pkg, error) {
+	lock, err := a.lockDownload(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock(lock)
+
+	pr := a.newProgress(PhaseDownload)
+
+	if err := a.downloadPartial(ctx, pr); err != nil {
+		return nil, err
+	}
+
+	pr.done()
+
+	return a.promotePartial()
+}
+
+// downloadPartial downloads the metadata archive into the partial file.
+// Failed attempts are retried with exponential backoff, resuming where the
+// previous attempt, or a previous run, stopped.
+func (a *Client) downloadPartial(ctx context.Context, pr *progress) error {
+	backoff := a.retryBackoff
+
+	for attempt := 0; ; attempt++ {
+		err := a.downloadAttempt(ctx, pr)
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= a.downloadRetries || !retryable(err) {
+			return err
+		}
+
+		if a.debugLoggerFn != nil {
+			a.debugLoggerFn("AUR metadata download failed, retrying in", backoff, err)
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		backoff *= 2
+	}
+}
+
+// downloadAttempt downloads the rest of the partial file. A partial file is
+// only resumed if the server still has the same version of the archive,
+// as identified by its ETag.
+func (a *Client) downloadAttempt(ctx context.Context, pr *progress) error {
+	offset, etag := a.partialState()
+
+	req, err := a.archiveRequest(ctx, a.endpoint)
+	if err != nil {
+		return err
+	}
+
+	// byte ranges refer to the compressed archive, so it must not be
+	// decompressed by the transport
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", etag)
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_WRONLY | os.O_CREATE
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || start != offset {
+			a.discardPartial()
+
+			return errRangeMismatch
+		}
+
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		offset = 0
+		flags |= os.O_TRUNC
+
+		if errE := a.savePartialETag(resp.Header.Get("ETag")); errE != nil {
+			return errE
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		a.discardPartial()
+
+		return errRangeMismatch
+	default:
+		return &statusError{code: resp.StatusCode, status: resp.Status}
+	}
+
+	f, err := os.OpenFile(a.partPath(), flags, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	total := int64(-1)
+	if resp.ContentLength > 0 {
+		total = offset + resp.ContentLength
+	}
+
+	// what was received is kept for the next attempt
+	if _, err := io.Copy(f, pr.reader(resp.Body, offset, total)); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// partialState returns the size and ETag of a resumable partial download.
+// A partial download without ETag can't be resumed and is discarded.
+func (a *Client) partialState() (int64, string) {
+	etag, err := os.ReadFile(a.etagPath())
+	if err != nil || len(etag) == 0 {
+		a.discardPartial()
+
+		return 0, ""
+	}
+
+	info, err := os.Stat(a.partPath())
+	if err != nil {
+		return 0, ""
+	}
+
+	return info.Size(), string(etag)
+}
+
+// savePartialETag records the ETag of a new partial download. Weak ETags
+// can't validate a range request and are not kept.
+func (a *Client) savePartialETag(etag string) error {
+	if etag == "" || strings.HasPrefix(etag, "W/") {
+		err := os.Remove(a.etagPath())
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		return nil
+	}
+
+	return os.WriteFile(a.etagPath(), []byte(etag), 0o600)
+}
+
+func (a *Client) discardPartial() {
+	os.Remove(a.partPath())
+	os.Remove(a.etagPath())
+}
+
+// promotePartial verifies the completed download and moves it into place
+// as the cache file. An invalid download is discarded.
+func (a *Client) promotePartial() ([]aur.Pkg, error) {
+	pkgs, compressed, err := a.decodePartial()
+	if err != nil {
+		a.discardPartial()
+
+		return nil, err
+	}
+
+	install := a.compressPartial
+	if compressed {
+		install = func() error { return os.Rename(a.partPath(), a.cacheFilePath) }
+	}
+
+	sumFn := func() (cacheSum, error) {
+		sum, errS := fileSum(a.cacheFilePath)
+		sum.Packages = len(pkgs)
+
+		return sum, errS
+	}
+
+	if errR := a.replaceCache(install, sumFn); errR != nil {
+		return nil, errR
+	}
+
+	a.discardPartial()
+
+	return pkgs, nil
+}
+
+// decodePartial checks and decodes the partial file. It also reports
+// whether the file is gzip compressed.
+func (a *Client) decodePartial() ([]aur.Pkg, bool, error) {
+	f, err := os.Open(a.partPath())
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+
+	pr := a.newProgress(PhaseParse)
+	br := bufio.NewReader(pr.reader(f, 0, sizeOf(f)))
+
+	magic, err := br.Peek(len(gzipMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, false, err
+	}
+
+	compressed := bytes.Equal(magic, gzipMagic)
+
+	var r io.Reader = br
+
+	if compressed {
+		zr, errZ := gzip.NewReader(br)
+		if errZ != nil {
+			return nil, false, fmt.Errorf("%w: %v", ErrInvalidDownload, errZ)
+		}
+		defer zr.Close()
+
+		r = zr
+	}
+
+	body := bufio.NewReader(r)
+	if errC := checkDownload(body); errC != nil {
+		return nil, false, errC
+	}
+
+	pkgs, err := DecodePkgs(body, a.fields...)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: unable to parse: %v", ErrInvalidDownload, err)
+	}
+
+	if errC := a.checkPackageCount(len(pkgs)); errC != nil {
+		return nil, false, errC
+	}
+
+	// the whole stream must be intact, not only the part the decoder read
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		return nil, false, fmt.Errorf("%w: %v", ErrInvalidDownload, err)
+	}
+
+	pr.done()
+
+	return pkgs, compressed, nil
+}
+
+// compressPartial writes the uncompressed partial file as the cache file.
+func (a *Client) compressPartial() error {
+	f, err := os.Open(a.partPath())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return writeCache(a.cacheFilePath, f)
+}
+
+// contentRangeStart returns the first byte position of a Content-Range
+// header like "bytes 100-199/200".
+func contentRangeStart(contentRange string) (int64, bool) {
+	rest, ok := cutPrefix(contentRange, "bytes ")
+	if !ok {
+		return 0, false
+	}
+
+	i := strings.IndexByte(rest, '-')
+	if i < 0 {
+		return 0, false
+	}
+
+	start, err := strconv.ParseInt(rest[:i], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return start, true
+}
+
+func cutPrefix(s, prefix string) (string, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return s, false
+	}
+
+	return s[len(prefix):], true
+}
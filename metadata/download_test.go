@@ -0,0 +1,328 @@
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rangeMockHTTP serves data with range request support. The body of the
+// n-th response is cut after cutAfter[n] bytes, if set.
+type rangeMockHTTP struct {
+	mu       sync.Mutex
+	data     []byte
+	etag     string
+	status   int
+	cutAfter map[int]int
+	ranges   []string
+}
+
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+
+func (m *rangeMockHTTP) setData(data []byte, etag string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.data, m.etag = data, etag
+}
+
+func (m *rangeMockHTTP) Do(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	call := len(m.ranges)
+	m.ranges = append(m.ranges, req.Header.Get("Range"))
+
+	if m.status != 0 {
+		return &http.Response{
+			StatusCode: m.status,
+			Status:     http.StatusText(m.status),
+			Body:       io.NopCloser(bytes.NewReader(nil)),
+		}, nil
+	}
+
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Etag": []string{m.etag}}}
+	body := m.data
+
+	if r := req.Header.Get("Range"); r != "" && req.Header.Get("If-Range") == m.etag {
+		start, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(r, "bytes="), "-"))
+		if err != nil {
+			return nil, err
+		}
+
+		if start >= len(m.data) {
+			resp.StatusCode = http.StatusRequestedRangeNotSatisfiable
+			resp.Body = io.NopCloser(bytes.NewReader(nil))
+
+			return resp, nil
+		}
+
+		resp.StatusCode = http.StatusPartialContent
+		resp.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(m.data)-1, len(m.data)))
+		body = m.data[start:]
+	}
+
+	resp.ContentLength = int64(len(body))
+
+	var r io.Reader = bytes.NewReader(body)
+	if n, ok := m.cutAfter[call]; ok {
+		r = io.MultiReader(bytes.NewReader(body[:n]), errReader{io.ErrUnexpectedEOF})
+	}
+
+	resp.Body = io.NopCloser(r)
+
+	return resp, nil
+}
+
+func testArchive(t *testing.T) []byte {
+	t.Helper()
+
+	testBytes, err := os.ReadFile("test.json")
+	require.NoError(t, err)
+
+	return gzipBytes(t, string(testBytes))
+}
+
+func TestClientResumesDownload(t *testing.T) {
+	t.Parallel()
+
+	archive := testArchive(t)
+	mock := &rangeMockHTTP{data: archive, etag: `"v1"`, cutAfter: map[int]int{0: 100, 1: 300}}
+
+	client, err := New(WithCacheFilePath(t.TempDir()+"/cache.json"), WithHTTPClient(mock),
+		WithDownloadRetries(3, time.Millisecond))
+	require.NoError(t, err)
+
+	assert.Equal(t, "11.3.0-1", yayVersion(t, client))
+	assert.Equal(t, []string{"", "bytes=100-", "bytes=400-"}, mock.ranges)
+
+	// the archive is promoted as is
+	cached, err := os.ReadFile(client.cacheFilePath)
+	require.NoError(t, err)
+	assert.Equal(t, archive, cached)
+
+	_, verified, err := client.verifyCache()
+	require.NoError(t, err)
+	assert.True(t, verified)
+	assert.NoFileExists(t, client.partPath())
+	assert.NoFileExists(t, client.etagPath())
+}
+
+func TestClientResumesAcrossRuns(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	cacheFilePath := t.TempDir() + "/cache.json"
+
+	mock := &rangeMockHTTP{data: testArchive(t), etag: `"v1"`, cutAfter: map[int]int{0: 100, 1: 0}}
+
+	client, err := New(WithCacheFilePath(cacheFilePath), WithHTTPClient(mock),
+		WithDownloadRetries(1, time.Millisecond))
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, client.Refresh(ctx), io.ErrUnexpectedEOF)
+	assert.FileExists(t, client.partPath())
+
+	client, err = New(WithCacheFilePath(cacheFilePath), WithHTTPClient(mock))
+	require.NoError(t, err)
+
+	require.NoError(t, client.Refresh(ctx))
+	assert.Equal(t, []string{"", "bytes=100-", "bytes=100-"}, mock.ranges)
+}
+
+func TestClientRestartsChangedDownload(t *testing.T) {
+	t.Parallel()
+
+	mock := &rangeMockHTTP{data: []byte("[]"), etag: `"v0"`, cutAfter: map[int]int{0: 1, 1: 0}}
+
+	client, err := New(WithCacheFilePath(t.TempDir()+"/cache.json"), WithHTTPClient(mock),
+		WithDownloadRetries(1, 0))
+	require.NoError(t, err)
+
+	require.Error(t, client.Refresh(context.Background()))
+
+	// the archive changed in between, If-Range makes the server send it whole
+	mock.setData(testArchive(t), `"v1"`)
+
+	assert.Equal(t, "11.3.0-1", yayVersion(t, client))
+	assert.Equal(t, []string{"", "bytes=1-", "bytes=1-"}, mock.ranges)
+}
+
+func TestClientDownloadWithoutETagNotResumed(t *testing.T) {
+	t.Parallel()
+
+	mock := &rangeMockHTTP{data: testArchive(t), cutAfter: map[int]int{0: 100}}
+
+	client, err := New(WithCacheFilePath(t.TempDir()+"/cache.json"), WithHTTPClient(mock),
+		WithDownloadRetries(1, 0))
+	require.NoError(t, err)
+
+	assert.Equal(t, "11.3.0-1", yayVersion(t, client))
+	assert.Equal(t, []string{"", ""}, mock.ranges)
+}
+
+func TestClientDownloadRetries(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	mock := &rangeMockHTTP{status: http.StatusServiceUnavailable}
+
+	client, err := New(WithCacheFilePath(t.TempDir()+"/cache.json"), WithHTTPClient(mock),
+		WithDownloadRetries(2, time.Millisecond))
+	require.NoError(t, err)
+
+	assert.ErrorContains(t, client.Refresh(ctx), "failed to download metadata")
+	assert.Len(t, mock.ranges, 3)
+
+	notFound := &rangeMockHTTP{status: http.StatusNotFound}
+
+	client, err = New(WithCacheFilePath(t.TempDir()+"/cache.json"), WithHTTPClient(notFound),
+		WithDownloadRetries(2, time.Millisecond))
+	require.NoError(t, err)
+
+	assert.ErrorContains(t, client.Refresh(ctx), "failed to download metadata")
+	assert.Len(t, notFound.ranges, 1)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	client, err = New(WithCacheFilePath(t.TempDir()+"/cache.json"), WithHTTPClient(mock),
+		WithDownloadRetries(2, time.Hour))
+	require.NoError(t, err)
+	assert.ErrorIs(t, client.Refresh(canceled), context.Canceled)
+
+	_, err = New(WithDownloadRetries(-1, 0))
+	assert.Error(t, err)
+}
+
+func TestClientInvalidDownloadDiscarded(t *testing.T) {
+	t.Parallel()
+
+	mock := &rangeMockHTTP{data: []byte("<html>Bad gateway</html>"), etag: `"v1"`}
+
+	client, err := New(WithCacheFilePath(t.TempDir()+"/cache.json"), WithHTTPClient(mock))
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, client.Refresh(context.Background()), ErrInvalidDownload)
+	assert.NoFileExists(t, client.partPath())
+	assert.NoFileExists(t, client.etagPath())
+	assert.NoFileExists(t, client.cacheFilePath)
+}
+
+func TestClientDownloadLocked(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	cacheFilePath := t.TempDir() + "/cache.json"
+
+	client, err := New(WithCacheFilePath(cacheFilePath), WithHTTPClient(&rangeMockHTTP{data: testArchive(t), etag: `"v1"`}))
+	require.NoError(t, err)
+
+	// another process is downloading
+	lock, ok, err := tryLock(client.lockPath())
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	canceled, cancel := context.WithTimeout(ctx, 3*lockRetryInterval)
+	defer cancel()
+
+	assert.ErrorIs(t, client.Refresh(canceled), context.DeadlineExceeded)
+	assert.NoFileExists(t, client.partPath())
+
+	unlock(lock)
+
+	require.NoError(t, client.Refresh(ctx))
+}
+
+// overlapHTTP records how many requests are served at once.
+type overlapHTTP struct {
+	*rangeMockHTTP
+	inFlight, maxInFlight *int32
+}
+
+func (m overlapHTTP) Do(req *http.Request) (*http.Response, error) {
+	n := atomic.AddInt32(m.inFlight, 1)
+	defer atomic.AddInt32(m.inFlight, -1)
+
+	for {
+		maxN := atomic.LoadInt32(m.maxInFlight)
+		if n <= maxN || atomic.CompareAndSwapInt32(m.maxInFlight, maxN, n) {
+			break
+		}
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	return m.rangeMockHTTP.Do(req)
+}
+
+func TestClientConcurrentDownloads(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	cacheFilePath := t.TempDir() + "/cache.json"
+	archive := testArchive(t)
+
+	var (
+		wg                    sync.WaitGroup
+		inFlight, maxInFlight int32
+	)
+
+	errs := make([]error, 4)
+
+	for i := range errs {
+		mock := overlapHTTP{
+			rangeMockHTTP: &rangeMockHTTP{data: archive, etag: `"v1"`, cutAfter: map[int]int{0: 200}},
+			inFlight:      &inFlight,
+			maxInFlight:   &maxInFlight,
+		}
+
+		client, err := New(WithCacheFilePath(cacheFilePath), WithHTTPClient(mock), WithDownloadRetries(1, 0))
+		require.NoError(t, err)
+
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			errs[i] = client.Refresh(ctx)
+		}(i)
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	// clients sharing a cache download one at a time
+	assert.Equal(t, int32(1), maxInFlight)
+
+	cached, err := os.ReadFile(cacheFilePath)
+	require.NoError(t, err)
+	assert.Equal(t, archive, cached)
+}
+
+func TestContentRangeStart(t *testing.T) {
+	t.Parallel()
+
+	start, ok := contentRangeStart("bytes 100-199/200")
+	assert.True(t, ok)
+	assert.Equal(t, int64(100), start)
+
+	for _, header := range []string{"", "bytes */200", "items 1-2/3"} {
+		_, ok := contentRangeStart(header)
+		assert.False(t, ok, header)
+	}
+}
@@ -0,0 +1,68 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// flight is a call in progress or completed.
+type flight struct {
+	done chan struct{}
+	ds   *dataset
+	err  error
+}
+
+// flightGroup deduplicates concurrent calls with the same key: callers
+// arriving while a call is in progress wait for it and share its result.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// do runs fn once for all concurrent callers of key. Callers stop waiting
+// when their own ctx is done. If the call failed only because the context of
+// the caller running it was canceled, the others retry with their own.
+func (g *flightGroup) do(ctx context.Context, key string,
+	fn func(ctx context.Context) (*dataset, error),
+) (*dataset, error) {
+	for {
+		g.mu.Lock()
+		if g.flights == nil {
+			g.flights = make(map[string]*flight)
+		}
+
+		if f, ok := g.flights[key]; ok {
+			g.mu.Unlock()
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-f.done:
+			}
+
+			if isContextErr(f.err) && ctx.Err() == nil {
+				continue
+			}
+
+			return f.ds, f.err
+		}
+
+		f := &flight{done: make(chan struct{})}
+		g.flights[key] = f
+		g.mu.Unlock()
+
+		f.ds, f.err = fn(ctx)
+
+		g.mu.Lock()
+		delete(g.flights, key)
+		g.mu.Unlock()
+		close(f.done)
+
+		return f.ds, f.err
+	}
+}
+
+func isContextErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
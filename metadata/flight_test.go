@@ -0,0 +1,102 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlightGroupDeduplicates(t *testing.T) {
+	t.Parallel()
+
+	var (
+		g     flightGroup
+		calls atomic.Int32
+		wg    sync.WaitGroup
+	)
+
+	release := make(chan struct{})
+	want := &dataset{}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ds, err := g.do(context.Background(), "k", func(ctx context.Context) (*dataset, error) {
+				calls.Add(1)
+				<-release
+
+				return want, nil
+			})
+			assert.NoError(t, err)
+			assert.Same(t, want, ds)
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestFlightGroupCallerContext(t *testing.T) {
+	t.Parallel()
+
+	var g flightGroup
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+
+	go func() {
+		_, err := g.do(leaderCtx, "k", func(ctx context.Context) (*dataset, error) {
+			close(started)
+			<-release
+
+			return nil, ctx.Err()
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+	}()
+
+	<-started
+
+	// a waiting caller gives up with its own context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := g.do(ctx, "k", func(ctx context.Context) (*dataset, error) {
+		return nil, errors.New("must not run")
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// a waiting caller retries when only the leader was canceled
+	result := make(chan *dataset)
+	want := &dataset{}
+
+	go func() {
+		ds, err := g.do(context.Background(), "k", func(ctx context.Context) (*dataset, error) {
+			return want, nil
+		})
+		assert.NoError(t, err)
+		result <- ds
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancelLeader()
+	close(release)
+
+	select {
+	case ds := <-result:
+		assert.Same(t, want, ds)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "caller did not retry")
+	}
+}
@@ -0,0 +1,54 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrOffline is returned when metadata would need to be downloaded by a
+// client created with WithOffline.
+var ErrOffline = errors.New("aur metadata not available offline")
+
+// DataInfo describes the metadata served by a client.
+type DataInfo struct {
+	// UpdatedAt is when the metadata was downloaded.
+	UpdatedAt time.Time
+	// Age is the time since UpdatedAt.
+	Age time.Duration
+	// Stale reports whether the metadata is older than the cache validity.
+	Stale bool
+	// RefreshError is set when the metadata is served from an expired cache
+	// because downloading fresh metadata failed.
+	RefreshError error
+}
+
+// DataInfo returns information about the metadata in use. It returns false
+// if no metadata has been loaded yet.
+func (a *Client) DataInfo() (DataInfo, bool) {
+	ds := a.unmarshalledCache.Load()
+	if ds == nil {
+		return DataInfo{}, false
+	}
+
+	age := time.Since(ds.modTime)
+
+	return DataInfo{
+		UpdatedAt:    ds.modTime,
+		Age:          age,
+		Stale:        age > a.cacheValidity,
+		RefreshError: ds.refreshErr,
+	}, true
+}
+
+// Load loads the metadata as a query would, from the cache when it is valid,
+// and returns information about it.
+func (a *Client) Load(ctx context.Context) (DataInfo, error) {
+	if _, err := a.cache(ctx); err != nil {
+		return DataInfo{}, err
+	}
+
+	info, _ := a.DataInfo()
+
+	return info, nil
+}
@@ -0,0 +1,119 @@
+package metadata
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expiredCache writes a cache file older than the default validity.
+func expiredCache(t *testing.T) string {
+	t.Helper()
+
+	cacheFilePath := t.TempDir() + "/cache.json"
+
+	f, err := os.Open("test.json")
+	require.NoError(t, err)
+	defer f.Close()
+
+	require.NoError(t, writeCache(cacheFilePath, f))
+
+	old := time.Now().Add(-2 * cacheValidity)
+	require.NoError(t, os.Chtimes(cacheFilePath, old, old))
+
+	return cacheFilePath
+}
+
+func TestClientOffline(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	mock := &syncMockHTTP{}
+	mock.set(http.StatusOK, nil)
+
+	client, err := New(WithCacheFilePath(t.TempDir()+"/cache.json"), WithHTTPClient(mock), WithOffline())
+	require.NoError(t, err)
+
+	_, err = client.cache(ctx)
+	assert.ErrorIs(t, err, ErrOffline)
+
+	client, err = New(WithCacheFilePath(expiredCache(t)), WithHTTPClient(mock), WithOffline())
+	require.NoError(t, err)
+
+	_, ok := client.DataInfo()
+	assert.False(t, ok)
+
+	assert.Equal(t, "11.3.0-1", yayVersion(t, client))
+
+	info, ok := client.DataInfo()
+	require.True(t, ok)
+	assert.True(t, info.Stale)
+	assert.InDelta(t, 2*cacheValidity, info.Age, float64(time.Minute))
+	assert.NoError(t, info.RefreshError)
+
+	assert.ErrorIs(t, client.Refresh(ctx), ErrOffline)
+	assert.NoError(t, client.refreshIfNeeded(ctx))
+	assert.Zero(t, mock.calls.Load())
+}
+
+func TestClientStaleFallback(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	mock := &syncMockHTTP{}
+	mock.set(http.StatusServiceUnavailable, nil)
+
+	// without fallback the error is returned
+	client, err := New(WithCacheFilePath(expiredCache(t)), WithHTTPClient(mock), WithDownloadRetries(0, 0))
+	require.NoError(t, err)
+
+	_, err = client.cache(ctx)
+	assert.ErrorContains(t, err, "failed to download metadata")
+
+	history, err := OpenHistory(t.TempDir())
+	require.NoError(t, err)
+
+	client, err = New(WithCacheFilePath(expiredCache(t)), WithHTTPClient(mock), WithStaleFallback(),
+		WithDownloadRetries(0, 0), WithHistory(history))
+	require.NoError(t, err)
+
+	assert.Equal(t, "11.3.0-1", yayVersion(t, client))
+
+	// the stale data is recorded like any loaded cache file
+	versions, err := history.Versions("yay")
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	assert.Equal(t, "11.3.0-1", versions[0].Version)
+
+	info, ok := client.DataInfo()
+	require.True(t, ok)
+	assert.True(t, info.Stale)
+	assert.ErrorContains(t, info.RefreshError, "failed to download metadata")
+
+	// nothing to fall back to
+	client, err = New(WithCacheFilePath(t.TempDir()+"/cache.json"), WithHTTPClient(mock), WithStaleFallback(),
+		WithDownloadRetries(0, 0))
+	require.NoError(t, err)
+
+	_, err = client.cache(ctx)
+	assert.ErrorContains(t, err, "failed to download metadata")
+}
+
+func TestClientDataInfoFresh(t *testing.T) {
+	t.Parallel()
+
+	client := newTestClient(t)
+	_, err := client.cache(context.Background())
+	require.NoError(t, err)
+
+	info, ok := client.DataInfo()
+	require.True(t, ok)
+	assert.False(t, info.Stale)
+	assert.Less(t, info.Age, time.Minute)
+	assert.WithinDuration(t, time.Now(), info.UpdatedAt, time.Minute)
+}
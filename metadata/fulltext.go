@@ -0,0 +1,331 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/Jguer/aur"
+)
+
+// BM25 parameters and the term frequency weight of each indexed field.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+
+	nameFieldWeight        = 3
+	keywordFieldWeight     = 2
+	descriptionFieldWeight = 1
+
+	// positionGap separates fields and keywords so phrases never span them.
+	positionGap = 16
+)
+
+// FullTextSearch searches package names, descriptions and keywords.
+//
+// Words are matched case insensitively and must all be present. OR between
+// words or phrases matches either side, binding looser than the implicit AND:
+// `sway OR i3 wayland` is `sway OR (i3 AND wayland)`. Double quotes match a
+// phrase, `"web browser"`. Results are ranked with BM25, names weighing most.
+//
+// The index is built on first use for every loaded dataset.
+// A limit of 0 returns every match.
+func (a *Client) FullTextSearch(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	groups, err := parseTextQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	ds, err := a.cache(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	index := ds.textIndex()
+	scores := map[int32]float64{}
+
+	for _, group := range groups {
+		for doc, score := range index.matchGroup(group) {
+			scores[doc] += score
+		}
+	}
+
+	results := make([]SearchResult, 0, len(scores))
+	for doc, score := range scores {
+		results = append(results, SearchResult{Pkg: ds.Pkgs[doc], Score: score})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+
+		return results[i].Pkg.Name < results[j].Pkg.Name
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// textClause is a word, or a phrase of several words.
+type textClause []string
+
+// parseTextQuery returns the OR separated groups of query, each a list of
+// clauses that must all match.
+func parseTextQuery(query string) ([][]textClause, error) {
+	groups := [][]textClause{}
+	group := []textClause{}
+
+	endGroup := func() {
+		if len(group) != 0 {
+			groups = append(groups, group)
+			group = []textClause{}
+		}
+	}
+
+	rest := query
+	for {
+		rest = strings.TrimLeft(rest, " \t\n")
+		if rest == "" {
+			break
+		}
+
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated phrase in %q", query)
+			}
+
+			if words := tokenize(rest[1 : end+1]); len(words) != 0 {
+				group = append(group, words)
+			}
+
+			rest = rest[end+2:]
+
+			continue
+		}
+
+		end := strings.IndexAny(rest, " \t\n\"")
+		if end < 0 {
+			end = len(rest)
+		}
+
+		word := rest[:end]
+		rest = rest[end:]
+
+		if word == "OR" {
+			endGroup()
+
+			continue
+		}
+
+		// a word like "foo-bar" matches like the phrase "foo bar"
+		if words := tokenize(word); len(words) != 0 {
+			group = append(group, words)
+		}
+	}
+
+	endGroup()
+
+	return groups, nil
+}
+
+// textIndex is an inverted index over package names, descriptions and keywords.
+type textIndex struct {
+	postings map[string][]posting
+	// positions holds the positions of all postings.
+	positions []int32
+	docLen    []float32
+	avgDocLen float64
+	docs      int
+}
+
+// posting is the occurrence of a term in a package.
+type posting struct {
+	doc    int32
+	tf     float32 // weighted by field
+	posOff int32
+	posLen int32
+}
+
+func (p *posting) positionsIn(index *textIndex) []int32 {
+	return index.positions[p.posOff : p.posOff+p.posLen]
+}
+
+func buildTextIndex(ds *dataset) *textIndex {
+	index := &textIndex{
+		postings: map[string][]posting{},
+		docLen:   make([]float32, len(ds.Pkgs)),
+	}
+
+	var totalLen float64
+
+	for i := range ds.Pkgs {
+		pkg := &ds.Pkgs[i]
+		if ds.ByName[pkg.Name] != i {
+			continue // duplicate
+		}
+
+		docLen := index.addDoc(int32(i), pkg)
+		index.docLen[i] = docLen
+		totalLen += float64(docLen)
+		index.docs++
+	}
+
+	if index.docs != 0 {
+		index.avgDocLen = totalLen / float64(index.docs)
+	}
+
+	return index
+}
+
+func (index *textIndex) addDoc(doc int32, pkg *aur.Pkg) float32 {
+	type termStats struct {
+		tf        float32
+		positions []int32
+	}
+
+	terms := map[string]*termStats{}
+	pos := int32(0)
+	docLen := float32(0)
+
+	addField := func(text string, weight float32) {
+		for _, word := range tokenize(text) {
+			ts, ok := terms[word]
+			if !ok {
+				ts = &termStats{}
+				terms[word] = ts
+			}
+
+			ts.tf += weight
+			ts.positions = append(ts.positions, pos)
+			docLen += weight
+			pos++
+		}
+
+		pos += positionGap
+	}
+
+	addField(pkg.Name, nameFieldWeight)
+	addField(pkg.Description, descriptionFieldWeight)
+
+	for _, keyword := range pkg.Keywords {
+		addField(keyword, keywordFieldWeight)
+	}
+
+	for term, ts := range terms {
+		index.postings[term] = append(index.postings[term], posting{
+			doc:    doc,
+			tf:     ts.tf,
+			posOff: int32(len(index.positions)),
+			posLen: int32(len(ts.positions)),
+		})
+		index.positions = append(index.positions, ts.positions...)
+	}
+
+	return docLen
+}
+
+// matchGroup returns the BM25 score of every package matching all clauses.
+func (index *textIndex) matchGroup(group []textClause) map[int32]float64 {
+	var scores map[int32]float64
+
+	for _, clause := range group {
+		clauseScores := index.matchClause(clause)
+
+		if scores == nil {
+			scores = clauseScores
+
+			continue
+		}
+
+		for doc, score := range scores {
+			if s, ok := clauseScores[doc]; ok {
+				scores[doc] = score + s
+			} else {
+				delete(scores, doc)
+			}
+		}
+	}
+
+	return scores
+}
+
+// matchClause returns the score of every package containing the clause words
+// at consecutive positions.
+func (index *textIndex) matchClause(clause textClause) map[int32]float64 {
+	first := index.postings[clause[0]]
+	scores := make(map[int32]float64, len(first))
+
+	for i := range first {
+		scores[first[i].doc] = index.bm25(clause[0], &first[i])
+	}
+
+	// candidate start positions of the phrase in each package
+	var starts map[int32][]int32
+	if len(clause) > 1 {
+		starts = make(map[int32][]int32, len(first))
+		for i := range first {
+			starts[first[i].doc] = first[i].positionsIn(index)
+		}
+	}
+
+	for offset, word := range clause[1:] {
+		next := make(map[int32]float64, len(scores))
+		postings := index.postings[word]
+
+		for i := range postings {
+			p := &postings[i]
+
+			score, ok := scores[p.doc]
+			if !ok {
+				continue
+			}
+
+			remaining := followedBy(starts[p.doc], p.positionsIn(index), int32(offset+1))
+			if len(remaining) == 0 {
+				continue
+			}
+
+			starts[p.doc] = remaining
+			next[p.doc] = score + index.bm25(word, p)
+		}
+
+		scores = next
+	}
+
+	return scores
+}
+
+// followedBy returns the starts which have a position at start+offset.
+func followedBy(starts, positions []int32, offset int32) []int32 {
+	remaining := []int32{}
+
+	for _, start := range starts {
+		for _, pos := range positions {
+			if pos == start+offset {
+				remaining = append(remaining, start)
+
+				break
+			}
+		}
+	}
+
+	return remaining
+}
+
+func (index *textIndex) bm25(term string, p *posting) float64 {
+	df := float64(len(index.postings[term]))
+	n := float64(index.docs)
+	idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+
+	tf := float64(p.tf)
+	norm := 1 - bm25B + bm25B*float64(index.docLen[p.doc])/index.avgDocLen
+
+	return idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+}
@@ -0,0 +1,131 @@
+package metadata
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Jguer/aur"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTextQuery(t *testing.T) {
+	t.Parallel()
+
+	groups, err := parseTextQuery(`Sway OR i3 "Tiling  WM" foo-bar OR`)
+	require.NoError(t, err)
+	assert.Equal(t, [][]textClause{
+		{{"sway"}},
+		{{"i3"}, {"tiling", "wm"}, {"foo", "bar"}},
+	}, groups)
+
+	groups, err = parseTextQuery(` "" -- `)
+	require.NoError(t, err)
+	assert.Empty(t, groups)
+
+	_, err = parseTextQuery(`"open`)
+	assert.Error(t, err)
+}
+
+func TestClientFullTextSearch(t *testing.T) {
+	t.Parallel()
+
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	type testcase struct {
+		desc  string
+		query string
+		limit int
+		want  []string
+	}
+
+	tests := []testcase{
+		{
+			desc:  "single word in name",
+			query: "liquidsfz",
+			want:  []string{"liquidsfz-git"},
+		},
+		{
+			desc:  "and",
+			query: "yay bin",
+			want:  []string{"yay-bin"},
+		},
+		{
+			desc:  "case insensitive description",
+			query: "PRE-COMPILED",
+			want:  []string{"yay-bin"},
+		},
+		{
+			desc:  "or",
+			query: "liquidsfz OR amdgpu",
+			want:  []string{"linux-amd-git", "liquidsfz-git"},
+		},
+		{
+			desc:  "phrase",
+			query: `"sfz sampler"`,
+			want:  []string{"liquidsfz-git"},
+		},
+		{
+			desc:  "phrase in wrong order",
+			query: `"sampler sfz"`,
+			want:  []string{},
+		},
+		{
+			desc:  "phrase does not span fields",
+			query: `"git sfz"`,
+			want:  []string{},
+		},
+		{
+			desc:  "name matches rank first",
+			query: "yay",
+			limit: 1,
+			want:  []string{"yay"},
+		},
+		{
+			desc:  "missing word",
+			query: "yay nothing",
+			want:  []string{},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			results, err := client.FullTextSearch(ctx, test.query, test.limit)
+			require.NoError(t, err)
+
+			names := resultNames(results)
+			if len(test.want) > 1 && test.limit == 0 {
+				assert.ElementsMatch(t, test.want, names)
+			} else {
+				assert.Equal(t, test.want, names)
+			}
+		})
+	}
+
+	_, err := client.FullTextSearch(ctx, `"open`, 0)
+	assert.Error(t, err)
+}
+
+func TestTextIndexBM25(t *testing.T) {
+	t.Parallel()
+
+	ds := newDataset([]aur.Pkg{
+		{Name: "editor", Description: "a text editor"},
+		{Name: "foo", Description: "editor plugin for an editor with many many many other words"},
+		{Name: "bar", Description: "unrelated"},
+		{Name: "editor", Description: "duplicate is not indexed"},
+	})
+
+	index := ds.textIndex()
+	assert.Same(t, index, ds.textIndex())
+	assert.Equal(t, 3, index.docs)
+
+	scores := index.matchGroup([]textClause{{"editor"}})
+	require.Len(t, scores, 2)
+	assert.Greater(t, scores[0], scores[1])
+
+	assert.Empty(t, index.matchGroup([]textClause{{"editor"}, {"unrelated"}}))
+	assert.Empty(t, index.matchGroup([]textClause{{"duplicate"}}))
+}
@@ -0,0 +1,397 @@
+package metadata
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jguer/aur"
+)
+
+const (
+	historyPrefix = "changes-"
+	historySuffix = ".jsonl.gz"
+)
+
+// History is a local store of the metadata over time. Every recorded
+// snapshot only stores the packages that changed since the previous one, in
+// a compressed file of its own, so the store stays compact and a crash never
+// damages earlier records.
+//
+// Changes to NumVotes and Popularity alone are not recorded, packages
+// returned by History carry the values of their last recorded change.
+// History is safe for concurrent use within a process.
+type History struct {
+	dir string
+
+	mu sync.Mutex
+	// digests of the latest recorded state, loaded on first Record
+	digests  map[string][sha256.Size]byte
+	lastTime time.Time
+}
+
+// historyRecord is a line of a history file. Name comes first so lines
+// can be matched without decoding them.
+type historyRecord struct {
+	Name    string   `json:"Name"`
+	Removed bool     `json:"Removed,omitempty"`
+	Pkg     *aur.Pkg `json:"Pkg,omitempty"`
+}
+
+// VersionRecord is a version of a package found in the history.
+type VersionRecord struct {
+	Version string    `json:"Version"`
+	Since   time.Time `json:"Since"`
+	// Removed is set for the package being deleted from the AUR,
+	// Version is then empty.
+	Removed bool `json:"Removed,omitempty"`
+}
+
+// OpenHistory opens the history store in dir, creating it if needed.
+func OpenHistory(dir string) (*History, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("unable to create history: %w", err)
+	}
+
+	return &History{dir: dir}, nil
+}
+
+// WithHistory records every new version of the metadata in h.
+func WithHistory(h *History) ClientOption {
+	return func(c *Client) error {
+		c.recorders = append(c.recorders, h)
+
+		return nil
+	}
+}
+
+// recorder keeps track of the metadata over time.
+type recorder interface {
+	Record(t time.Time, pkgs []aur.Pkg) error
+}
+
+// record passes ds to the recorders. Failing to record is logged, it does
+// not prevent serving the data.
+func (a *Client) record(ds *dataset) {
+	for _, r := range a.recorders {
+		if err := r.Record(ds.modTime, ds.Pkgs); err != nil && a.debugLoggerFn != nil {
+			a.debugLoggerFn("AUR metadata unable to record", err)
+		}
+	}
+}
+
+// Record stores the packages that changed since the previous snapshot as the
+// state at t, as well as the removed ones. Snapshots not newer than the
+// latest recorded one are ignored.
+func (h *History) Record(t time.Time, pkgs []aur.Pkg) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.digests == nil {
+		if err := h.loadDigests(); err != nil {
+			return err
+		}
+	}
+
+	if !t.After(h.lastTime) {
+		return nil
+	}
+
+	records := make([]historyRecord, 0)
+	digests := make(map[string][sha256.Size]byte, len(pkgs))
+
+	for i := range pkgs {
+		pkg := &pkgs[i]
+		if _, ok := digests[pkg.Name]; ok {
+			continue
+		}
+
+		digest, err := pkgDigest(pkg)
+		if err != nil {
+			return err
+		}
+
+		digests[pkg.Name] = digest
+
+		if prev, ok := h.digests[pkg.Name]; !ok || prev != digest {
+			records = append(records, historyRecord{Name: pkg.Name, Pkg: pkg})
+		}
+	}
+
+	for name := range h.digests {
+		if _, ok := digests[name]; !ok {
+			records = append(records, historyRecord{Name: name, Removed: true})
+		}
+	}
+
+	if len(records) != 0 {
+		if err := h.writeFile(t, records); err != nil {
+			return err
+		}
+	}
+
+	h.digests = digests
+	h.lastTime = t
+
+	return nil
+}
+
+// At returns the package called name as it was at t, or nil if it did not
+// exist then.
+func (h *History) At(name string, t time.Time) (*aur.Pkg, error) {
+	var pkg *aur.Pkg
+
+	err := h.scan(t, name, func(_ time.Time, rec *historyRecord) {
+		pkg = rec.Pkg
+	})
+
+	return pkg, err
+}
+
+// Versions returns the versions the package called name went through, oldest
+// first, and when it was removed and added again.
+func (h *History) Versions(name string) ([]VersionRecord, error) {
+	versions := make([]VersionRecord, 0)
+
+	err := h.scan(time.Time{}, name, func(t time.Time, rec *historyRecord) {
+		var last *VersionRecord
+		if len(versions) != 0 {
+			last = &versions[len(versions)-1]
+		}
+
+		switch {
+		case rec.Removed:
+			if last != nil && !last.Removed {
+				versions = append(versions, VersionRecord{Since: t, Removed: true})
+			}
+		case last == nil || last.Removed || last.Version != rec.Pkg.Version:
+			versions = append(versions, VersionRecord{Version: rec.Pkg.Version, Since: t})
+		}
+	})
+
+	return versions, err
+}
+
+// Snapshot returns all packages as they were at t, sorted by name.
+func (h *History) Snapshot(t time.Time) ([]aur.Pkg, error) {
+	state := make(map[string]*aur.Pkg)
+
+	err := h.scan(t, "", func(_ time.Time, rec *historyRecord) {
+		if rec.Removed {
+			delete(state, rec.Name)
+		} else {
+			state[rec.Name] = rec.Pkg
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs := make([]aur.Pkg, 0, len(state))
+	for _, pkg := range state {
+		pkgs = append(pkgs, *pkg)
+	}
+
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].Name < pkgs[j].Name })
+
+	return pkgs, nil
+}
+
+// recordFile is a file recorded at a point in time.
+type recordFile struct {
+	path string
+	time time.Time
+}
+
+// listRecords returns the files in dir named prefix, the recording time in
+// Unix nanoseconds and suffix, oldest first.
+func listRecords(dir, prefix, suffix string) ([]recordFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]recordFile, 0, len(entries))
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+
+		nanos, errP := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix), 10, 64)
+		if errP != nil {
+			continue
+		}
+
+		files = append(files, recordFile{path: filepath.Join(dir, name), time: time.Unix(0, nanos)})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].time.Before(files[j].time) })
+
+	return files, nil
+}
+
+// recordName returns the name of the file recorded at t.
+func recordName(prefix string, t time.Time, suffix string) string {
+	return fmt.Sprintf("%s%019d%s", prefix, t.UnixNano(), suffix)
+}
+
+// scan calls fn with the records of the snapshots up to until, or all of
+// them if until is zero, in order. If name is set only its records are
+// decoded.
+func (h *History) scan(until time.Time, name string, fn func(t time.Time, rec *historyRecord)) error {
+	files, err := listRecords(h.dir, historyPrefix, historySuffix)
+	if err != nil {
+		return fmt.Errorf("unable to read history: %w", err)
+	}
+
+	var prefix []byte
+
+	if name != "" {
+		encoded, errM := json.Marshal(name)
+		if errM != nil {
+			return errM
+		}
+
+		prefix = append(append([]byte(`{"Name":`), encoded...), ',')
+	}
+
+	for _, file := range files {
+		if !until.IsZero() && file.time.After(until) {
+			break
+		}
+
+		if err := scanHistoryFile(file, prefix, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func scanHistoryFile(file recordFile, prefix []byte, fn func(t time.Time, rec *historyRecord)) error {
+	f, err := os.Open(file.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return fmt.Errorf("unable to read history file %s: %w", file.path, err)
+	}
+	defer zr.Close()
+
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if prefix != nil && !bytes.HasPrefix(line, prefix) {
+			continue
+		}
+
+		var rec historyRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return fmt.Errorf("unable to read history file %s: %w", file.path, err)
+		}
+
+		fn(file.time, &rec)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("unable to read history file %s: %w", file.path, err)
+	}
+
+	return nil
+}
+
+// loadDigests rebuilds the latest recorded state. Must hold h.mu.
+func (h *History) loadDigests() error {
+	state := make(map[string][sha256.Size]byte)
+
+	var scanErr error
+
+	err := h.scan(time.Time{}, "", func(t time.Time, rec *historyRecord) {
+		h.lastTime = t
+
+		if rec.Removed {
+			delete(state, rec.Name)
+
+			return
+		}
+
+		digest, err := pkgDigest(rec.Pkg)
+		if err != nil && scanErr == nil {
+			scanErr = err
+		}
+
+		state[rec.Name] = digest
+	})
+	if err != nil {
+		return err
+	}
+
+	if scanErr != nil {
+		return scanErr
+	}
+
+	h.digests = state
+
+	return nil
+}
+
+// writeFile atomically writes the records of the snapshot at t.
+func (h *History) writeFile(t time.Time, records []historyRecord) error {
+	f, err := os.CreateTemp(h.dir, historyPrefix+"*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	zw := gzip.NewWriter(f)
+	enc := json.NewEncoder(zw)
+
+	for i := range records {
+		if err := enc.Encode(&records[i]); err != nil {
+			return err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filepath.Join(h.dir, recordName(historyPrefix, t, historySuffix)))
+}
+
+// pkgDigest identifies the content of pkg, ignoring the fields which change
+// without the package being updated.
+func pkgDigest(pkg *aur.Pkg) ([sha256.Size]byte, error) {
+	stable := *pkg
+	stable.NumVotes = 0
+	stable.Popularity = 0
+
+	b, err := json.Marshal(&stable)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+
+	return sha256.Sum256(b), nil
+}
@@ -0,0 +1,154 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Jguer/aur"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func historyFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(dir, historyPrefix+"*"+historySuffix))
+	require.NoError(t, err)
+
+	return files
+}
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	h, err := OpenHistory(dir)
+	require.NoError(t, err)
+
+	day := func(d int) time.Time { return time.Date(2022, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	require.NoError(t, h.Record(day(1), []aur.Pkg{
+		{Name: "yay", Version: "11.0.0-1", NumVotes: 1},
+		{Name: "paru", Version: "1.0.0-1"},
+	}))
+	// only votes changed
+	require.NoError(t, h.Record(day(2), []aur.Pkg{
+		{Name: "yay", Version: "11.0.0-1", NumVotes: 2},
+		{Name: "paru", Version: "1.0.0-1"},
+	}))
+	assert.Len(t, historyFiles(t, dir), 1)
+
+	require.NoError(t, h.Record(day(3), []aur.Pkg{
+		{Name: "yay", Version: "11.1.0-1"},
+		{Name: "paru", Version: "1.0.0-1"},
+	}))
+	require.NoError(t, h.Record(day(5), []aur.Pkg{
+		{Name: "yay", Version: "11.1.0-1"},
+	}))
+	require.NoError(t, h.Record(day(7), []aur.Pkg{
+		{Name: "yay", Version: "11.2.0-1"},
+		{Name: "paru", Version: "1.0.0-1"},
+	}))
+	// not newer than the last record
+	require.NoError(t, h.Record(day(6), []aur.Pkg{}))
+	assert.Len(t, historyFiles(t, dir), 4)
+
+	pkg, err := h.At("yay", day(4))
+	require.NoError(t, err)
+	require.NotNil(t, pkg)
+	assert.Equal(t, "11.1.0-1", pkg.Version)
+
+	pkg, err = h.At("paru", day(6))
+	require.NoError(t, err)
+	assert.Nil(t, pkg)
+
+	pkg, err = h.At("yay", day(1).Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Nil(t, pkg)
+
+	versions, err := h.Versions("yay")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"11.0.0-1", "11.1.0-1", "11.2.0-1"}, versionStrings(versions))
+	assert.True(t, versions[1].Since.Equal(day(3)))
+
+	versions, err = h.Versions("paru")
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	assert.Equal(t, "1.0.0-1", versions[0].Version)
+	assert.True(t, versions[1].Removed)
+	assert.True(t, versions[1].Since.Equal(day(5)))
+	assert.Equal(t, "1.0.0-1", versions[2].Version)
+
+	versions, err = h.Versions("missing")
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+
+	pkgs, err := h.Snapshot(day(3))
+	require.NoError(t, err)
+	require.Len(t, pkgs, 2)
+	assert.Equal(t, "paru", pkgs[0].Name)
+	assert.Equal(t, "11.1.0-1", pkgs[1].Version)
+
+	// a reopened store continues from the recorded state
+	reopened, err := OpenHistory(dir)
+	require.NoError(t, err)
+	require.NoError(t, reopened.Record(day(8), []aur.Pkg{
+		{Name: "yay", Version: "11.2.0-1"},
+		{Name: "paru", Version: "1.0.0-1"},
+	}))
+	assert.Len(t, historyFiles(t, dir), 4)
+}
+
+func versionStrings(versions []VersionRecord) []string {
+	out := make([]string, 0, len(versions))
+	for _, v := range versions {
+		out = append(out, v.Version)
+	}
+
+	return out
+}
+
+func TestHistoryPrefixMatch(t *testing.T) {
+	t.Parallel()
+
+	h, err := OpenHistory(t.TempDir())
+	require.NoError(t, err)
+
+	require.NoError(t, h.Record(time.Unix(1, 0), []aur.Pkg{
+		{Name: "yay", Version: "1"},
+		{Name: "yay-bin", Version: "2"},
+	}))
+
+	pkg, err := h.At("yay", time.Unix(1, 0))
+	require.NoError(t, err)
+	require.NotNil(t, pkg)
+	assert.Equal(t, "1", pkg.Version)
+}
+
+func TestClientWithHistory(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	h, err := OpenHistory(dir)
+	require.NoError(t, err)
+
+	client := newTestClient(t, WithHistory(h))
+	assert.Equal(t, "11.3.0-1", yayVersion(t, client))
+
+	files := historyFiles(t, dir)
+	require.Len(t, files, 1)
+
+	info, err := os.Stat(client.cacheFilePath)
+	require.NoError(t, err)
+
+	pkg, err := h.At("yay", info.ModTime())
+	require.NoError(t, err)
+	require.NotNil(t, pkg)
+	assert.Equal(t, "11.3.0-1", pkg.Version)
+
+	pkgs, err := h.Snapshot(time.Now())
+	require.NoError(t, err)
+	assert.Len(t, pkgs, 12)
+}
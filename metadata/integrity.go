@@ -0,0 +1,255 @@
+package metadata
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// defaultMinPackages is the fewest packages a download must contain.
+	defaultMinPackages = 1
+	// defaultMaxShrink is the largest share of packages a download may lose
+	// compared to the current cache.
+	defaultMaxShrink = 0.5
+)
+
+var (
+	// ErrCorruptCache is returned when the cache file fails verification.
+	// Unless offline, a corrupt cache is quarantined and downloaded again.
+	ErrCorruptCache = errors.New("aur metadata cache is corrupt")
+
+	// ErrInvalidDownload is returned when downloaded metadata fails the
+	// sanity checks. The previous cache is kept.
+	ErrInvalidDownload = errors.New("aur metadata download is invalid")
+)
+
+// cacheSum describes a cache file as written, to detect corruption.
+// ModTime tells whether the sum belongs to the cache file on disk, which
+// may have been replaced by a writer not maintaining sums.
+//
+// The size is checked before reading the cache file, the SHA256 and the
+// package count while it is decoded, so loading doesn't read it twice.
+type cacheSum struct {
+	SHA256   string `json:"SHA256"`
+	Size     int64  `json:"Size"`
+	Packages int    `json:"Packages"`
+	ModTime  int64  `json:"ModTime"`
+}
+
+// sumPath returns where the sum of the cache file is stored.
+func (a *Client) sumPath() string {
+	return a.cacheFilePath + ".sum"
+}
+
+// corruptPath returns where a corrupt cache file is moved.
+func (a *Client) corruptPath() string {
+	return a.cacheFilePath + ".corrupt"
+}
+
+func readSum(path string) (cacheSum, error) {
+	var sum cacheSum
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return sum, err
+	}
+
+	if err := json.Unmarshal(b, &sum); err != nil {
+		return sum, fmt.Errorf("%w: unreadable sum: %v", ErrCorruptCache, err)
+	}
+
+	return sum, nil
+}
+
+func writeSum(path string, sum cacheSum) error {
+	b, err := json.Marshal(sum)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// saveSum stores sum for the cache file just written.
+func (a *Client) saveSum(sum cacheSum) error {
+	info, err := os.Stat(a.cacheFilePath)
+	if err != nil {
+		return err
+	}
+
+	sum.ModTime = info.ModTime().UnixNano()
+
+	return writeSum(a.sumPath(), sum)
+}
+
+// replaceCache moves a new cache file into place with install, then stores
+// the sum computed by sumFn for it. Failing to store the sum only leaves the
+// cache unverified.
+func (a *Client) replaceCache(install func() error, sumFn func() (cacheSum, error)) error {
+	// a stale sum would fail the new cache
+	if err := os.Remove(a.sumPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := install(); err != nil {
+		return err
+	}
+
+	sum, err := sumFn()
+	if err == nil {
+		err = a.saveSum(sum)
+	}
+
+	if err != nil && a.debugLoggerFn != nil {
+		a.debugLoggerFn("AUR metadata unable to write cache sum", err)
+	}
+
+	return nil
+}
+
+// verifyCache checks the size of the cache file against its sum, the rest
+// is checked by loadCache. It returns false if there is no sum for it, as
+// for caches written by older versions.
+func (a *Client) verifyCache() (cacheSum, bool, error) {
+	sum, err := readSum(a.sumPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return sum, false, nil
+		}
+
+		return sum, false, err
+	}
+
+	info, err := os.Stat(a.cacheFilePath)
+	if err != nil {
+		return sum, false, err
+	}
+
+	// replaced by another writer
+	if info.ModTime().UnixNano() != sum.ModTime {
+		return sum, false, nil
+	}
+
+	if info.Size() != sum.Size {
+		return sum, false, fmt.Errorf("%w: size mismatch", ErrCorruptCache)
+	}
+
+	return sum, true, nil
+}
+
+// checkPackageCount rejects a download of n packages that is too small on
+// its own or compared to the current cache.
+func (a *Client) checkPackageCount(n int) error {
+	if n < a.minPackages {
+		return fmt.Errorf("%w: only %d packages", ErrInvalidDownload, n)
+	}
+
+	if prev := a.currentPackages(); float64(n) < (1-a.maxShrink)*float64(prev) {
+		return fmt.Errorf("%w: %d packages, down from %d", ErrInvalidDownload, n, prev)
+	}
+
+	return nil
+}
+
+// currentPackages returns the package count of the current cache, 0 if
+// unknown.
+func (a *Client) currentPackages() int {
+	if sum, err := readSum(a.sumPath()); err == nil {
+		return sum.Packages
+	}
+
+	if ds := a.unmarshalledCache.Load(); ds != nil {
+		return len(ds.Pkgs)
+	}
+
+	return 0
+}
+
+// fileSum returns the sum of the file at path, without a package count.
+func fileSum(path string) (cacheSum, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return cacheSum{}, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return cacheSum{}, err
+	}
+
+	h := sha256.New()
+
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return cacheSum{}, err
+	}
+
+	return cacheSum{SHA256: hex.EncodeToString(h.Sum(nil)), Size: size, ModTime: info.ModTime().UnixNano()}, nil
+}
+
+// quarantine moves the corrupt cache file aside for inspection and removes
+// the files derived from it. Must hold a.updateMu.
+func (a *Client) quarantine(cause error) {
+	if a.debugLoggerFn != nil {
+		a.debugLoggerFn("AUR metadata cache quarantined", a.corruptPath(), cause)
+	}
+
+	if err := os.Rename(a.cacheFilePath, a.corruptPath()); err != nil && a.debugLoggerFn != nil {
+		a.debugLoggerFn("AUR metadata unable to quarantine cache", err)
+	}
+
+	os.Remove(a.sumPath())
+	os.Remove(a.snapshotPath())
+}
+
+// checkDownload rejects downloads which are not a JSON array, such as an
+// error page served with a 200 status.
+func checkDownload(br *bufio.Reader) error {
+	const sniffLen = 512
+
+	head, err := br.Peek(sniffLen)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return err
+	}
+
+	trimmed := bytes.TrimLeft(head, " \t\r\n")
+	if len(trimmed) == 0 {
+		return fmt.Errorf("%w: empty", ErrInvalidDownload)
+	}
+
+	if trimmed[0] != '[' {
+		const quoteLen = 32
+		if len(trimmed) > quoteLen {
+			trimmed = trimmed[:quoteLen]
+		}
+
+		return fmt.Errorf("%w: unexpected content %q", ErrInvalidDownload, trimmed)
+	}
+
+	return nil
+}
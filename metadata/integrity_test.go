@@ -0,0 +1,189 @@
+package metadata
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Jguer/aur"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// corruptCache flips a byte in the middle of the cache file, keeping its
+// modification time as bit rot would.
+func corruptCache(t *testing.T, cacheFilePath string) {
+	t.Helper()
+
+	info, err := os.Stat(cacheFilePath)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(cacheFilePath)
+	require.NoError(t, err)
+
+	b[len(b)/2] ^= 0xff
+	require.NoError(t, os.WriteFile(cacheFilePath, b, 0o600))
+	require.NoError(t, os.Chtimes(cacheFilePath, info.ModTime(), info.ModTime()))
+}
+
+func TestClientCacheSum(t *testing.T) {
+	t.Parallel()
+
+	client := newTestClient(t)
+	assert.Equal(t, "11.3.0-1", yayVersion(t, client))
+
+	sum, err := readSum(client.sumPath())
+	require.NoError(t, err)
+	assert.Equal(t, 12, sum.Packages)
+
+	sum, verified, err := client.verifyCache()
+	require.NoError(t, err)
+	assert.True(t, verified)
+	assert.Equal(t, 12, sum.Packages)
+}
+
+func TestClientChecksCacheSum(t *testing.T) {
+	t.Parallel()
+
+	online := newTestClient(t)
+	assert.Equal(t, "11.3.0-1", yayVersion(t, online))
+
+	client, err := New(WithCacheFilePath(online.cacheFilePath), WithOffline())
+	require.NoError(t, err)
+
+	// the content no longer matches the sum, while the size still does
+	sum, err := readSum(client.sumPath())
+	require.NoError(t, err)
+
+	sum.SHA256 = strings.Repeat("0", len(sum.SHA256))
+	require.NoError(t, writeSum(client.sumPath(), sum))
+
+	_, err = client.Get(context.Background(), &aur.Query{By: aur.Name, Needles: []string{"yay"}})
+	assert.ErrorIs(t, err, ErrCorruptCache)
+	assert.ErrorContains(t, err, "checksum mismatch")
+}
+
+func TestClientHealsCorruptCache(t *testing.T) {
+	t.Parallel()
+	cacheFilePath := t.TempDir() + "/cache.json"
+
+	testBytes, err := os.ReadFile("test.json")
+	require.NoError(t, err)
+
+	mock := &syncMockHTTP{}
+	mock.set(http.StatusOK, testBytes)
+
+	client, err := New(WithCacheFilePath(cacheFilePath), WithHTTPClient(mock))
+	require.NoError(t, err)
+	assert.Equal(t, "11.3.0-1", yayVersion(t, client))
+
+	corruptCache(t, cacheFilePath)
+
+	healed, err := New(WithCacheFilePath(cacheFilePath), WithHTTPClient(mock))
+	require.NoError(t, err)
+	assert.Equal(t, "11.3.0-1", yayVersion(t, healed))
+	assert.Equal(t, int32(2), mock.calls.Load())
+	assert.FileExists(t, cacheFilePath+".corrupt")
+
+	_, verified, err := healed.verifyCache()
+	require.NoError(t, err)
+	assert.True(t, verified)
+
+	corruptCache(t, cacheFilePath)
+
+	offline, err := New(WithCacheFilePath(cacheFilePath), WithOffline())
+	require.NoError(t, err)
+
+	_, err = offline.Get(context.Background(), &aur.Query{By: aur.Name, Needles: []string{"yay"}})
+	assert.ErrorIs(t, err, ErrCorruptCache)
+	assert.NoFileExists(t, cacheFilePath)
+}
+
+func TestClientRejectsInvalidDownloads(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	testBytes, err := os.ReadFile("test.json")
+	require.NoError(t, err)
+
+	mock := &syncMockHTTP{}
+	mock.set(http.StatusOK, testBytes)
+
+	client, err := New(WithCacheFilePath(t.TempDir()+"/cache.json"), WithHTTPClient(mock))
+	require.NoError(t, err)
+	require.NoError(t, client.Refresh(ctx))
+
+	for _, body := range []string{
+		"<!DOCTYPE html><html><body>Service unavailable</body></html>",
+		"",
+		"[]",
+		`[{"Name":"yay"`,
+	} {
+		mock.set(http.StatusOK, []byte(body))
+		assert.ErrorIs(t, client.Refresh(ctx), ErrInvalidDownload, body)
+	}
+
+	// the previous cache is kept
+	_, verified, err := client.verifyCache()
+	require.NoError(t, err)
+	assert.True(t, verified)
+
+	mock.set(http.StatusOK, testBytes)
+
+	strict, err := New(WithCacheFilePath(t.TempDir()+"/cache.json"), WithHTTPClient(mock), WithMinPackages(100))
+	require.NoError(t, err)
+	assert.ErrorIs(t, strict.Refresh(ctx), ErrInvalidDownload)
+
+	_, err = New(WithMinPackages(-1))
+	assert.Error(t, err)
+}
+
+func TestClientRejectsShrunkDownloads(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	cacheFilePath := t.TempDir() + "/cache.json"
+
+	testBytes, err := os.ReadFile("test.json")
+	require.NoError(t, err)
+
+	var pkgs []aur.Pkg
+	require.NoError(t, json.Unmarshal(testBytes, &pkgs))
+
+	shrunk, err := json.Marshal(pkgs[:5])
+	require.NoError(t, err)
+
+	mock := &syncMockHTTP{}
+	mock.set(http.StatusOK, testBytes)
+
+	client, err := New(WithCacheFilePath(cacheFilePath), WithHTTPClient(mock))
+	require.NoError(t, err)
+	require.NoError(t, client.Refresh(ctx))
+
+	mock.set(http.StatusOK, shrunk)
+	assert.ErrorContains(t, client.Refresh(ctx), "5 packages, down from 12")
+
+	// the previous cache is kept
+	sum, verified, err := client.verifyCache()
+	require.NoError(t, err)
+	assert.True(t, verified)
+	assert.Equal(t, 12, sum.Packages)
+
+	lenient, err := New(WithCacheFilePath(cacheFilePath), WithHTTPClient(mock), WithMaxShrink(1))
+	require.NoError(t, err)
+	require.NoError(t, lenient.Refresh(ctx))
+
+	_, err = New(WithMaxShrink(1.5))
+	assert.Error(t, err)
+}
+
+func TestCheckDownload(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, checkDownload(bufio.NewReader(strings.NewReader("\n  [{}]"))))
+	assert.ErrorIs(t, checkDownload(bufio.NewReader(strings.NewReader(" \n"))), ErrInvalidDownload)
+	assert.ErrorContains(t, checkDownload(bufio.NewReader(strings.NewReader("<html>"))), `"<html>"`)
+}
@@ -0,0 +1,316 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Jguer/aur"
+	"github.com/itchyny/gojq"
+)
+
+// maxCompiledQueries bounds the compiled query cache.
+const maxCompiledQueries = 256
+
+// Query runs the jq expression expr on every package separately and returns
+// all the values it produces, in dump order. Packages have the layout of the
+// metadata dump, so `select(.NumVotes > 100 and .Maintainer == null)` finds
+// popular orphans and `.Name` lists package names. Aggregates over all the
+// packages, like `length`, need QueryAll.
+//
+// vars defines named variables usable in expr, keys may omit the leading $.
+// Prefer them over building expressions from strings: compiled expressions
+// are cached by their text and variable names.
+func (a *Client) Query(ctx context.Context, expr string, vars map[string]any) ([]any, error) {
+	results := []any{}
+
+	err := a.runQuery(ctx, expr, vars, func(_ *aur.Pkg, v any) {
+		results = append(results, v)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// QueryAll runs the jq expression expr once, on the array of all the
+// packages, and returns the values it produces. It allows aggregates such as
+// `length` or `group_by(.Maintainer) | map({(.[0].Maintainer // ""): length}) | add`,
+// but holds every package as a jq value at once, so prefer Query for
+// filtering. vars are handled like in Query.
+func (a *Client) QueryAll(ctx context.Context, expr string, vars map[string]any) ([]any, error) {
+	code, values, err := a.compileQuery(expr, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	ds, err := a.cache(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	input := make([]any, len(ds.Pkgs))
+	for i := range ds.Pkgs {
+		input[i] = pkgToMap(&ds.Pkgs[i], nil)
+	}
+
+	results := []any{}
+	iter := code.RunWithContext(ctx, input, values...)
+
+	for v, ok := iter.Next(); ok; v, ok = iter.Next() {
+		if errQ, ok := v.(error); ok {
+			return nil, fmt.Errorf("query failed: %w", errQ)
+		}
+
+		results = append(results, v)
+	}
+
+	return results, nil
+}
+
+// QueryPkgs runs expr like Query and decodes the resulting objects into
+// packages. Results that are not objects are an error.
+func (a *Client) QueryPkgs(ctx context.Context, expr string, vars map[string]any) ([]aur.Pkg, error) {
+	pkgs := []aur.Pkg{}
+
+	var errDecode error
+
+	err := a.runQuery(ctx, expr, vars, func(_ *aur.Pkg, v any) {
+		if errDecode != nil {
+			return
+		}
+
+		pkg, errM := mapToPkg(v)
+		if errM != nil {
+			errDecode = errM
+
+			return
+		}
+
+		pkgs = append(pkgs, *pkg)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if errDecode != nil {
+		return nil, errDecode
+	}
+
+	return pkgs, nil
+}
+
+// runQuery calls fn with every value produced by expr for each package.
+func (a *Client) runQuery(ctx context.Context, expr string, vars map[string]any,
+	fn func(pkg *aur.Pkg, v any),
+) error {
+	code, values, err := a.compileQuery(expr, vars)
+	if err != nil {
+		return err
+	}
+
+	ds, err := a.cache(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range ds.Pkgs {
+		pkg := &ds.Pkgs[i]
+		iter := code.RunWithContext(ctx, pkgToMap(pkg, nil), values...)
+
+		for v, ok := iter.Next(); ok; v, ok = iter.Next() {
+			if err, ok := v.(error); ok {
+				return fmt.Errorf("query failed on %s: %w", pkg.Name, err)
+			}
+
+			fn(pkg, v)
+		}
+	}
+
+	return nil
+}
+
+// compileQuery compiles expr with the variables vars, returning the values
+// to run it with.
+func (a *Client) compileQuery(expr string, vars map[string]any) (*gojq.Code, []any, error) {
+	names, values, err := queryVariables(vars)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	code, err := a.compile(expr, names)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return code, values, nil
+}
+
+// compile returns the compiled form of expr using the given variable names,
+// reusing earlier compilations.
+func (a *Client) compile(expr string, names []string) (*gojq.Code, error) {
+	key := expr + "\x00" + strings.Join(names, "\x00")
+
+	a.compiledMu.Lock()
+	code, ok := a.compiled[key]
+	a.compiledMu.Unlock()
+
+	if ok {
+		return code, nil
+	}
+
+	parsed, err := gojq.Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse query: %w", err)
+	}
+
+	code, err = gojq.Compile(parsed, gojq.WithVariables(names))
+	if err != nil {
+		return nil, fmt.Errorf("unable to compile query: %w", err)
+	}
+
+	a.compiledMu.Lock()
+	if len(a.compiled) >= maxCompiledQueries {
+		a.compiled = make(map[string]*gojq.Code, maxCompiledQueries)
+	}
+	a.compiled[key] = code
+	a.compiledMu.Unlock()
+
+	return code, nil
+}
+
+// queryVariables returns the sorted variable names and their values
+// converted to the types gojq understands.
+func queryVariables(vars map[string]any) (names []string, values []any, err error) {
+	names = make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	values = make([]any, 0, len(names))
+
+	for i, name := range names {
+		v, err := normalizeValue(vars[name])
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid query variable %s: %w", name, err)
+		}
+
+		values = append(values, v)
+
+		if !strings.HasPrefix(name, "$") {
+			names[i] = "$" + name
+		}
+	}
+
+	return names, values, nil
+}
+
+// normalizeValue converts v to the JSON-like values gojq operates on.
+func normalizeValue(v any) (any, error) {
+	switch v := v.(type) {
+	case nil, bool, int, float64, string, *big.Int:
+		return v, nil
+	case []any, map[string]any:
+		// may still contain other types
+	case []string:
+		values := make([]any, len(v))
+		for i := range v {
+			values[i] = v[i]
+		}
+
+		return values, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var normalized any
+	if err := json.Unmarshal(b, &normalized); err != nil {
+		return nil, err
+	}
+
+	return normalized, nil
+}
+
+// mapToPkg is the inverse of pkgToMap.
+func mapToPkg(v any) (*aur.Pkg, error) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("query result is not a package: %v", v)
+	}
+
+	pkg := &aur.Pkg{}
+	pv := reflect.ValueOf(pkg).Elem()
+
+	for name, value := range m {
+		i, ok := pkgFieldIndex[name]
+		if !ok || value == nil {
+			continue
+		}
+
+		if err := setPkgField(pv.Field(i), value); err != nil {
+			return nil, fmt.Errorf("invalid package field %s: %w", name, err)
+		}
+	}
+
+	return pkg, nil
+}
+
+func setPkgField(f reflect.Value, value any) error {
+	switch f.Kind() {
+	case reflect.String:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("expected string, got %T", value)
+		}
+
+		f.SetString(s)
+	case reflect.Int:
+		switch n := value.(type) {
+		case int:
+			f.SetInt(int64(n))
+		case float64:
+			f.SetInt(int64(math.Round(n)))
+		default:
+			return fmt.Errorf("expected number, got %T", value)
+		}
+	case reflect.Float64:
+		switch n := value.(type) {
+		case int:
+			f.SetFloat(float64(n))
+		case float64:
+			f.SetFloat(n)
+		default:
+			return fmt.Errorf("expected number, got %T", value)
+		}
+	case reflect.Slice:
+		values, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("expected array, got %T", value)
+		}
+
+		strs := make([]string, 0, len(values))
+		for _, v := range values {
+			s, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("expected string, got %T", v)
+			}
+
+			strs = append(strs, s)
+		}
+
+		f.Set(reflect.ValueOf(strs))
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/Jguer/aur"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, opts ...ClientOption) *Client {
+	t.Helper()
+
+	testBytes, err := os.ReadFile("test.json")
+	require.NoError(t, err)
+
+	client, err := New(append([]ClientOption{
+		WithCacheFilePath(t.TempDir() + "/cache.json"),
+		WithHTTPClient(&MockHTTP{bytesToReturn: testBytes}),
+	}, opts...)...)
+	require.NoError(t, err)
+
+	return client
+}
+
+func TestClientQuery(t *testing.T) {
+	t.Parallel()
+
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	got, err := client.Query(ctx, `select(.NumVotes > $votes and .Maintainer == $m) | .Name`,
+		map[string]any{"votes": 100, "$m": "jguer"})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"yay", "yay-bin"}, got)
+
+	got, err = client.Query(ctx, `select(.OutOfDate != null) | {Name, OutOfDate}`, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []any{map[string]any{"Name": "linux-ath-dfs", "OutOfDate": 1662556251}}, got)
+
+	got, err = client.Query(ctx, `select(.Name | IN($names[])) | .Version`,
+		map[string]any{"names": []string{"yay", "testpackage"}})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"11.3.0-1", "0.4.0-1"}, got)
+
+	got, err = client.Query(ctx, `.Keywords[]? | select(. == $k)`,
+		map[string]any{"k": struct{}{}})
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	_, err = client.Query(ctx, `select(`, nil)
+	assert.ErrorContains(t, err, "unable to parse query")
+
+	_, err = client.Query(ctx, `$undefined`, nil)
+	assert.ErrorContains(t, err, "unable to compile query")
+
+	_, err = client.Query(ctx, `.Name | error`, nil)
+	assert.ErrorContains(t, err, "query failed on liquidsfz-git")
+
+	_, err = client.Query(ctx, `.`, map[string]any{"bad": make(chan int)})
+	assert.ErrorContains(t, err, "invalid query variable")
+}
+
+func TestClientQueryAll(t *testing.T) {
+	t.Parallel()
+
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	got, err := client.QueryAll(ctx, `length`, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []any{12}, got)
+
+	got, err = client.QueryAll(ctx, `map(select(.NumVotes > $votes)) | length`, map[string]any{"votes": 100})
+	require.NoError(t, err)
+	assert.Equal(t, []any{2}, got)
+
+	got, err = client.QueryAll(ctx,
+		`group_by(.Maintainer) | map({maintainer: .[0].Maintainer, count: length}) | max_by(.count)`, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []any{map[string]any{"maintainer": "Terence", "count": 5}}, got)
+
+	got, err = client.QueryAll(ctx, `map(.Name) | sort | .[0]`, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []any{"jack-audio-tools-carla"}, got)
+
+	// each package alone is a different input
+	got, err = client.Query(ctx, `length`, nil)
+	require.NoError(t, err)
+	assert.Len(t, got, 12)
+
+	_, err = client.QueryAll(ctx, `error("no")`, nil)
+	assert.ErrorContains(t, err, "query failed")
+}
+
+func TestClientQueryPkgs(t *testing.T) {
+	t.Parallel()
+
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	pkgs, err := client.QueryPkgs(ctx, `select(.Provides[]? == $p)`, map[string]any{"p": "yay"})
+	require.NoError(t, err)
+	require.Len(t, pkgs, 2)
+
+	want, err := client.Get(ctx, &aur.Query{By: aur.Name, Needles: []string{"yay-bin"}})
+	require.NoError(t, err)
+	assert.Equal(t, want[0], pkgs[0])
+
+	pkgs, err = client.QueryPkgs(ctx, `select(.Name == "yay") | {Name, NumVotes: (.NumVotes + 0.4)}`, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []aur.Pkg{{Name: "yay", NumVotes: 1855}}, pkgs)
+
+	_, err = client.QueryPkgs(ctx, `.Name`, nil)
+	assert.ErrorContains(t, err, "not a package")
+
+	_, err = client.QueryPkgs(ctx, `{Name: 1}`, nil)
+	assert.ErrorContains(t, err, "invalid package field Name")
+}
+
+func TestClientQueryCompileCache(t *testing.T) {
+	t.Parallel()
+
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	for _, needle := range []string{"git", "kmod", "lv2lint"} {
+		_, err := client.Get(ctx, &aur.Query{By: aur.Depends, Needles: []string{needle}})
+		require.NoError(t, err)
+	}
+
+	assert.Len(t, client.compiled, 1)
+
+	for i := 0; i < maxCompiledQueries; i++ {
+		_, err := client.compile(fmt.Sprintf(". + %d", i), nil)
+		require.NoError(t, err)
+	}
+
+	assert.Len(t, client.compiled, 1) // evicted when full
+}
@@ -0,0 +1,102 @@
+//go:build !unix
+
+package metadata
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// staleLockAge is the age after which a lock is considered abandoned even
+// if its holder seems alive, as process IDs get reused.
+const staleLockAge = time.Hour
+
+// tryLock takes an exclusive lock by creating the file at path without
+// blocking, and writes the process ID to it. It returns false if another
+// process holds the lock. Locks left behind by crashed processes are broken.
+func tryLock(path string) (*os.File, bool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		if errB := breakStaleLock(path); errB != nil {
+			return nil, false, errB
+		}
+
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if _, err := fmt.Fprintln(f, os.Getpid()); err != nil {
+		unlock(f)
+
+		return nil, false, err
+	}
+
+	return f, true, nil
+}
+
+// breakStaleLock removes the lock at path if its holder exited or it is
+// older than staleLockAge. The next tryLock may then take it.
+func breakStaleLock(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	pid, errP := strconv.Atoi(string(bytes.TrimSpace(content)))
+	// a lock without PID may still be being written
+	holderExited := errP == nil && !processAlive(pid)
+
+	if !holderExited && time.Since(info.ModTime()) < staleLockAge {
+		return nil
+	}
+
+	// another process may have broken the lock and taken it meanwhile
+	if current, errR := os.ReadFile(path); errR != nil || !bytes.Equal(current, content) {
+		return nil
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unable to break stale lock: %w", err)
+	}
+
+	return nil
+}
+
+// processAlive reports whether a process with the given ID runs. Where
+// this can't be known it reports true, leaving stale locks to their age.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+
+	p.Release()
+
+	return true
+}
+
+// unlock releases a lock taken by tryLock.
+func unlock(f *os.File) {
+	f.Close()
+	os.Remove(f.Name())
+}
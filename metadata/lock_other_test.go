@@ -0,0 +1,45 @@
+//go:build !unix
+
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTryLockBreaksStaleLock(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "cache.json.lock")
+
+	lock, ok, err := tryLock(path)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// held by a live process
+	_, ok, err = tryLock(path)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// left behind by a crash long ago
+	lock.Close()
+
+	old := time.Now().Add(-2 * staleLockAge)
+	require.NoError(t, os.Chtimes(path, old, old))
+
+	_, ok, err = tryLock(path)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	lock, ok, err = tryLock(path)
+	require.NoError(t, err)
+	require.True(t, ok)
+	unlock(lock)
+
+	assert.NoFileExists(t, path)
+}
@@ -0,0 +1,36 @@
+//go:build unix
+
+package metadata
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLock takes an exclusive advisory lock on the file at path without
+// blocking. It returns false if another process holds the lock.
+func tryLock(path string) (*os.File, bool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, false, nil
+		}
+
+		return nil, false, err
+	}
+
+	return f, true, nil
+}
+
+// unlock releases a lock taken by tryLock.
+func unlock(f *os.File) {
+	// closing the file releases the lock
+	f.Close()
+}
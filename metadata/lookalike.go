@@ -0,0 +1,303 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Jguer/aur"
+)
+
+const (
+	defaultMinSimilarity = 0.75
+	defaultVoteRatio     = 0.1
+	defaultRecentAge     = 90 * 24 * time.Hour
+
+	// similarities given to names that only differ by confusable characters
+	// or by a variant suffix
+	homoglyphSimilarity = 0.95
+	variantSimilarity   = 0.9
+)
+
+// variantSuffixes are appended to names of alternative builds of a package.
+var variantSuffixes = []string{
+	"-bin", "-git", "-appimage", "-nightly", "-beta", "-stable",
+	"-latest", "-dev", "-svn", "-hg", "-official", "-full",
+}
+
+// confusables maps characters to the one they are easily mistaken for.
+var confusables = strings.NewReplacer(
+	"0", "o", "1", "l", "i", "l", "3", "e", "5", "s",
+	"rn", "m", "vv", "w",
+	"-", "", "_", "", ".", "",
+)
+
+// LookalikeKind is how a name resembles another.
+type LookalikeKind int
+
+const (
+	// LookalikeTypo is a name a few edits away.
+	LookalikeTypo LookalikeKind = iota + 1
+	// LookalikeHomoglyph is a name only differing by confusable characters
+	// or separators, like "google-chrorne" or "google_chrome".
+	LookalikeHomoglyph
+	// LookalikeVariant is the same name with another variant suffix, like
+	// "google-chrome-bin".
+	LookalikeVariant
+)
+
+func (k LookalikeKind) String() string {
+	switch k {
+	case LookalikeTypo:
+		return "typo"
+	case LookalikeHomoglyph:
+		return "homoglyph"
+	case LookalikeVariant:
+		return "variant"
+	}
+
+	panic("invalid LookalikeKind")
+}
+
+// MarshalText makes lookalike kinds readable in JSON reports.
+func (k LookalikeKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *LookalikeKind) UnmarshalText(text []byte) error {
+	for c := LookalikeTypo; c <= LookalikeVariant; c++ {
+		if c.String() == string(text) {
+			*k = c
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid lookalike kind %q", text)
+}
+
+// LookalikeOptions tunes Lookalikes. The zero value uses the defaults.
+type LookalikeOptions struct {
+	// MinSimilarity is the lowest name similarity reported, 0.75 by default.
+	MinSimilarity float64
+	// VoteRatio is the largest share of the votes of the impersonated package
+	// an impostor has, 0.1 by default.
+	VoteRatio float64
+	// RecentAge is the age under which a variant is recent enough to be
+	// suspicious, 90 days by default. Established variants are common.
+	RecentAge time.Duration
+	// Limit is the maximum number of lookalikes, 0 means no limit.
+	Limit int
+}
+
+// Lookalike is a package named like the checked one.
+type Lookalike struct {
+	Pkg        aur.Pkg       `json:"Pkg"`
+	Similarity float64       `json:"Similarity"`
+	Kind       LookalikeKind `json:"Kind"`
+	// SameMaintainer is set when both packages have the same maintainer,
+	// who is then unlikely to impersonate themselves.
+	SameMaintainer bool `json:"SameMaintainer"`
+	// Age is the time since the package was first submitted.
+	Age time.Duration `json:"Age"`
+	// Impostor names the package of the pair likely impersonating the
+	// other, empty when nothing is suspicious.
+	Impostor string `json:"Impostor,omitempty"`
+	// Reasons explain why Impostor is suspected.
+	Reasons []string `json:"Reasons,omitempty"`
+}
+
+// LookalikeReport lists the packages named like Name.
+type LookalikeReport struct {
+	Name string `json:"Name"`
+	// Pkg is the package called Name, nil if there is none.
+	Pkg *aur.Pkg `json:"Pkg"`
+	// Suspicious is set when the package called Name is likely to
+	// impersonate one of its lookalikes.
+	Suspicious bool `json:"Suspicious"`
+	// Lookalikes are sorted by decreasing similarity.
+	Lookalikes []Lookalike `json:"Lookalikes"`
+}
+
+// Lookalikes finds packages named like name, to catch typosquatting before
+// depending on a package. Each pair is checked for an impostor: a package
+// submitted later by another maintainer that gathered few votes compared to
+// the package it resembles. opts may be nil.
+func (a *Client) Lookalikes(ctx context.Context, name string, opts *LookalikeOptions) (*LookalikeReport, error) {
+	ds, err := a.cache(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	o := LookalikeOptions{}
+	if opts != nil {
+		o = *opts
+	}
+
+	if o.MinSimilarity == 0 {
+		o.MinSimilarity = defaultMinSimilarity
+	}
+
+	if o.VoteRatio == 0 {
+		o.VoteRatio = defaultVoteRatio
+	}
+
+	if o.RecentAge == 0 {
+		o.RecentAge = defaultRecentAge
+	}
+
+	report := &LookalikeReport{Name: name, Pkg: ds.lookup(name), Lookalikes: []Lookalike{}}
+	target := newNameForms(name)
+	now := time.Now()
+
+	for i := range ds.Pkgs {
+		if i%4096 == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		pkg := &ds.Pkgs[i]
+		if pkg.Name == name || ds.ByName[pkg.Name] != i {
+			continue
+		}
+
+		sim, kind := target.similarity(newNameForms(pkg.Name))
+		if sim < o.MinSimilarity {
+			continue
+		}
+
+		lookalike := Lookalike{
+			Pkg:        *pkg,
+			Similarity: sim,
+			Kind:       kind,
+			Age:        now.Sub(time.Unix(int64(pkg.FirstSubmitted), 0)),
+		}
+
+		if report.Pkg != nil {
+			lookalike.SameMaintainer = pkg.Maintainer != "" && pkg.Maintainer == report.Pkg.Maintainer
+
+			if reasons := impostorReasons(report.Pkg, pkg, kind, now, &o); reasons != nil {
+				lookalike.Impostor, lookalike.Reasons = pkg.Name, reasons
+			} else if targetReasons := impostorReasons(pkg, report.Pkg, kind, now, &o); targetReasons != nil {
+				lookalike.Impostor, lookalike.Reasons = name, targetReasons
+				report.Suspicious = true
+			}
+		}
+
+		report.Lookalikes = append(report.Lookalikes, lookalike)
+	}
+
+	sort.SliceStable(report.Lookalikes, func(i, j int) bool {
+		li, lj := &report.Lookalikes[i], &report.Lookalikes[j]
+		if li.Similarity != lj.Similarity {
+			return li.Similarity > lj.Similarity
+		}
+
+		return li.Pkg.Name < lj.Pkg.Name
+	})
+
+	if o.Limit > 0 && len(report.Lookalikes) > o.Limit {
+		report.Lookalikes = report.Lookalikes[:o.Limit]
+	}
+
+	return report, nil
+}
+
+// impostorReasons returns why suspect likely impersonates orig, or nil if
+// it does not look like it.
+func impostorReasons(orig, suspect *aur.Pkg, kind LookalikeKind, now time.Time, o *LookalikeOptions) []string {
+	if suspect.Maintainer != "" && suspect.Maintainer == orig.Maintainer {
+		return nil
+	}
+
+	if suspect.FirstSubmitted <= orig.FirstSubmitted {
+		return nil
+	}
+
+	if float64(suspect.NumVotes) > o.VoteRatio*float64(orig.NumVotes) {
+		return nil
+	}
+
+	age := now.Sub(time.Unix(int64(suspect.FirstSubmitted), 0))
+	if kind == LookalikeVariant && age > o.RecentAge {
+		return nil
+	}
+
+	reasons := []string{
+		fmt.Sprintf("named like %s (%s)", orig.Name, kind),
+		fmt.Sprintf("submitted %s after it", formatDays(time.Duration(suspect.FirstSubmitted-orig.FirstSubmitted)*time.Second)),
+		fmt.Sprintf("%d votes against %d", suspect.NumVotes, orig.NumVotes),
+	}
+
+	if suspect.Maintainer == "" {
+		reasons = append(reasons, "orphaned")
+	} else {
+		reasons = append(reasons, fmt.Sprintf("maintained by %s instead of %s", suspect.Maintainer, orig.Maintainer))
+	}
+
+	if age <= o.RecentAge {
+		reasons = append(reasons, fmt.Sprintf("only %s old", formatDays(age)))
+	}
+
+	return reasons
+}
+
+func formatDays(d time.Duration) string {
+	days := int(d.Hours() / 24)
+	if days == 1 {
+		return "1 day"
+	}
+
+	return fmt.Sprintf("%d days", days)
+}
+
+// nameForms are the forms of a package name compared to find lookalikes.
+type nameForms struct {
+	name string
+	// skeleton has confusable characters replaced and separators removed.
+	skeleton string
+	// base has its variant suffix removed.
+	base string
+}
+
+func newNameForms(name string) nameForms {
+	name = strings.ToLower(name)
+	base := name
+
+	for _, suffix := range variantSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			base = strings.TrimSuffix(name, suffix)
+
+			break
+		}
+	}
+
+	return nameForms{name: name, skeleton: confusables.Replace(name), base: base}
+}
+
+// similarity returns how alike both names are between 0 and 1, and how.
+func (f nameForms) similarity(other nameForms) (float64, LookalikeKind) {
+	if f.base == other.base {
+		return variantSimilarity, LookalikeVariant
+	}
+
+	if f.skeleton == other.skeleton {
+		return homoglyphSimilarity, LookalikeHomoglyph
+	}
+
+	la, lb := utf8.RuneCountInString(f.name), utf8.RuneCountInString(other.name)
+
+	longest, diff := la, la-lb
+	if lb > la {
+		longest, diff = lb, lb-la
+	}
+
+	// cheap bound, the distance is at least the difference in length
+	if diff > longest/2 {
+		return 0, LookalikeTypo
+	}
+
+	return 1 - float64(levenshtein(f.name, other.name, true))/float64(longest), LookalikeTypo
+}
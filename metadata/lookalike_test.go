@@ -0,0 +1,124 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Jguer/aur"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func daysAgo(days int) int {
+	return int(time.Now().Add(-time.Duration(days) * 24 * time.Hour).Unix())
+}
+
+func lookalikePkgs() []aur.Pkg {
+	return []aur.Pkg{
+		{Name: "google-chrome", Maintainer: "google", NumVotes: 2000, FirstSubmitted: daysAgo(4000)},
+		{Name: "google-chrone", Maintainer: "mallory", NumVotes: 1, FirstSubmitted: daysAgo(10)},
+		{Name: "google_chrome", Maintainer: "google", NumVotes: 3, FirstSubmitted: daysAgo(20)},
+		{Name: "google-chrome-bin", Maintainer: "eve", NumVotes: 0, FirstSubmitted: daysAgo(5)},
+		{Name: "google-chrome-git", Maintainer: "bob", NumVotes: 4, FirstSubmitted: daysAgo(1000)},
+		{Name: "chromium", Maintainer: "alice", NumVotes: 500, FirstSubmitted: daysAgo(3000)},
+	}
+}
+
+func lookalikeNames(report *LookalikeReport) []string {
+	names := []string{}
+	for i := range report.Lookalikes {
+		names = append(names, report.Lookalikes[i].Pkg.Name)
+	}
+
+	return names
+}
+
+func TestClientLookalikes(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	client := newDatasetClient(t, lookalikePkgs())
+
+	report, err := client.Lookalikes(ctx, "google-chrome", nil)
+	require.NoError(t, err)
+	require.NotNil(t, report.Pkg)
+	assert.False(t, report.Suspicious)
+	assert.Equal(t, []string{"google_chrome", "google-chrone", "google-chrome-bin", "google-chrome-git"},
+		lookalikeNames(report))
+
+	byName := map[string]*Lookalike{}
+	for i := range report.Lookalikes {
+		byName[report.Lookalikes[i].Pkg.Name] = &report.Lookalikes[i]
+	}
+
+	typo := byName["google-chrone"]
+	assert.Equal(t, LookalikeTypo, typo.Kind)
+	assert.InDelta(t, 1-1.0/13, typo.Similarity, 1e-9)
+	assert.Equal(t, "google-chrone", typo.Impostor)
+	assert.Contains(t, typo.Reasons, "maintained by mallory instead of google")
+
+	// same maintainer
+	assert.True(t, byName["google_chrome"].SameMaintainer)
+	assert.Equal(t, LookalikeHomoglyph, byName["google_chrome"].Kind)
+	assert.Empty(t, byName["google_chrome"].Impostor)
+
+	// recent variant by someone else
+	assert.Equal(t, LookalikeVariant, byName["google-chrome-bin"].Kind)
+	assert.Equal(t, "google-chrome-bin", byName["google-chrome-bin"].Impostor)
+
+	// established variant
+	assert.Empty(t, byName["google-chrome-git"].Impostor)
+
+	report, err = client.Lookalikes(ctx, "google-chrone", &LookalikeOptions{Limit: 1})
+	require.NoError(t, err)
+	assert.True(t, report.Suspicious)
+	require.Len(t, report.Lookalikes, 1)
+	assert.Equal(t, "google-chrone", report.Lookalikes[0].Impostor)
+
+	// names not in the AUR are compared without suspicion
+	report, err = client.Lookalikes(ctx, "chromiun", nil)
+	require.NoError(t, err)
+	assert.Nil(t, report.Pkg)
+	assert.Equal(t, []string{"chromium"}, lookalikeNames(report))
+	assert.Empty(t, report.Lookalikes[0].Impostor)
+
+	b, err := json.Marshal(report)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"Kind":"typo"`)
+
+	var decoded LookalikeReport
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, report.Lookalikes, decoded.Lookalikes)
+
+	var kind LookalikeKind
+	assert.Error(t, kind.UnmarshalText([]byte("typosquat")))
+}
+
+func TestNameSimilarity(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		a, b string
+		sim  float64
+		kind LookalikeKind
+	}{
+		{"yay", "yay-bin", variantSimilarity, LookalikeVariant},
+		{"yay-git", "yay-bin", variantSimilarity, LookalikeVariant},
+		{"paru", "parv", 0.75, LookalikeTypo},
+		{"google-chrome", "google-chrorne", homoglyphSimilarity, LookalikeHomoglyph},
+		{"python-requests", "python-requets", 1 - 1.0/15, LookalikeTypo},
+		{"python-requests", "python-reqeusts", 1 - 1.0/15, LookalikeTypo},
+		{"firefox", "linux", 1 - 5.0/7, LookalikeTypo},
+		{"firefox", "fx", 0, LookalikeTypo},
+		// lengths are compared in characters, not bytes
+		{"mpv-ü", "mpv", 1 - 2.0/5, LookalikeTypo},
+	}
+
+	for _, tc := range testCases {
+		sim, kind := newNameForms(tc.a).similarity(newNameForms(tc.b))
+		assert.InDelta(t, tc.sim, sim, 1e-9, tc.a+" "+tc.b)
+		assert.Equal(t, tc.kind, kind, tc.a+" "+tc.b)
+	}
+}
@@ -0,0 +1,135 @@
+package metadata
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// progressInterval is the minimum time between two progress reports.
+const progressInterval = 100 * time.Millisecond
+
+// Phase is a step of loading the metadata.
+type Phase int
+
+const (
+	// PhaseDownload is downloading the metadata. Metadata from a custom
+	// source is parsed while it is received.
+	PhaseDownload Phase = iota + 1
+	// PhaseParse is parsing downloaded metadata or the cache file.
+	PhaseParse
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseDownload:
+		return "download"
+	case PhaseParse:
+		return "parse"
+	}
+
+	panic("invalid Phase")
+}
+
+// Progress describes how far a phase got.
+type Progress struct {
+	Phase Phase
+	// Bytes is the number of bytes read so far, compressed if the data is.
+	Bytes int64
+	// Total is the expected number of bytes, -1 when unknown.
+	Total   int64
+	Elapsed time.Duration
+	// Done is set on the last report of a successful phase.
+	Done bool
+}
+
+// ProgressFn is called periodically while the metadata is loaded.
+type ProgressFn func(Progress)
+
+// progress tracks a phase. A nil *progress reports nothing.
+type progress struct {
+	fn    ProgressFn
+	p     Progress
+	start time.Time
+	last  time.Time
+}
+
+// newProgress starts tracking phase, it returns nil without a progress
+// function.
+func (a *Client) newProgress(phase Phase) *progress {
+	if a.progressFn == nil {
+		return nil
+	}
+
+	return &progress{
+		fn:    a.progressFn,
+		p:     Progress{Phase: phase, Total: -1},
+		start: time.Now(),
+	}
+}
+
+// reader counts the bytes read from r, which continues from offset up to
+// total if positive.
+func (pr *progress) reader(r io.Reader, offset, total int64) io.Reader {
+	if pr == nil {
+		return r
+	}
+
+	pr.p.Bytes = offset
+	if total > 0 {
+		pr.p.Total = total
+	}
+
+	return progressReader{r: r, pr: pr}
+}
+
+func (pr *progress) add(n int) {
+	pr.p.Bytes += int64(n)
+
+	if now := time.Now(); now.Sub(pr.last) >= progressInterval {
+		pr.last = now
+		pr.report()
+	}
+}
+
+// done reports the end of the phase.
+func (pr *progress) done() {
+	if pr == nil {
+		return
+	}
+
+	pr.p.Done = true
+	pr.report()
+}
+
+func (pr *progress) report() {
+	pr.p.Elapsed = time.Since(pr.start)
+	pr.fn(pr.p)
+}
+
+type progressReader struct {
+	r  io.Reader
+	pr *progress
+}
+
+func (r progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.pr.add(n)
+
+	return n, err
+}
+
+// sizeOf returns the size of r if it is a file, or -1.
+func sizeOf(r io.Reader) int64 {
+	f, ok := r.(*os.File)
+	if !ok {
+		return -1
+	}
+
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return -1
+	}
+
+	return info.Size()
+}
@@ -0,0 +1,100 @@
+package metadata
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type progressRecorder struct {
+	mu      sync.Mutex
+	reports []Progress
+}
+
+func (r *progressRecorder) record(p Progress) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.reports = append(r.reports, p)
+}
+
+func (r *progressRecorder) last() Progress {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.reports[len(r.reports)-1]
+}
+
+func TestClientProgress(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	info, err := os.Stat("test.json")
+	require.NoError(t, err)
+
+	cacheFilePath := t.TempDir() + "/cache.json"
+	download := &progressRecorder{}
+
+	client, err := New(WithCacheFilePath(cacheFilePath), WithFile("test.json"), WithProgress(download.record))
+	require.NoError(t, err)
+	require.NoError(t, client.Refresh(ctx))
+
+	assert.GreaterOrEqual(t, len(download.reports), 2)
+	assert.Equal(t, Progress{Phase: PhaseDownload, Bytes: info.Size(), Total: info.Size(), Done: true},
+		withoutElapsed(download.last()))
+
+	cacheInfo, err := os.Stat(cacheFilePath)
+	require.NoError(t, err)
+
+	parse := &progressRecorder{}
+
+	client, err = New(WithCacheFilePath(cacheFilePath), WithProgress(parse.record))
+	require.NoError(t, err)
+	assert.Equal(t, "11.3.0-1", yayVersion(t, client))
+
+	assert.Equal(t, Progress{Phase: PhaseParse, Bytes: cacheInfo.Size(), Total: cacheInfo.Size(), Done: true},
+		withoutElapsed(parse.last()))
+}
+
+func TestClientProgressUnknownTotal(t *testing.T) {
+	t.Parallel()
+
+	recorder := &progressRecorder{}
+	client := newTestClient(t, WithProgress(recorder.record))
+	require.NoError(t, client.Refresh(context.Background()))
+
+	var download Progress
+
+	for _, p := range recorder.reports {
+		if p.Phase == PhaseDownload {
+			download = p
+		}
+	}
+
+	assert.Equal(t, int64(-1), download.Total)
+	assert.True(t, download.Done)
+	assert.Positive(t, download.Bytes)
+
+	last := recorder.last()
+	assert.Equal(t, PhaseParse, last.Phase)
+	assert.Equal(t, download.Bytes, last.Total)
+	assert.True(t, last.Done)
+}
+
+func withoutElapsed(p Progress) Progress {
+	p.Elapsed = 0
+
+	return p
+}
+
+func TestPhaseString(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "download", PhaseDownload.String())
+	assert.Equal(t, "parse", PhaseParse.String())
+	assert.Panics(t, func() { _ = Phase(0).String() })
+}
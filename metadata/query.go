@@ -3,45 +3,69 @@ package metadata
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/Jguer/aur"
-	"github.com/itchyny/gojq"
-	"github.com/mitchellh/mapstructure"
 )
 
 const joiner = " or "
 
 // Get returns a list of packages that provide the given search term.
+// Results are sorted and paginated as requested by the query.
 func (a *Client) Get(ctx context.Context, query *aur.Query) ([]aur.Pkg, error) {
 	found := make([]aur.Pkg, 0, len(query.Needles))
 	if len(query.Needles) == 0 {
 		return found, nil
 	}
 
-	iterFound, errNeedle := a.gojqGetBatch(ctx, query)
+	var (
+		iterFound []aur.Pkg
+		errNeedle error
+	)
+
+	switch {
+	// like the RPC, an exact lookup without By is an info lookup by name
+	case (query.By == aur.Name || query.By == 0) && !query.Contains:
+		iterFound, errNeedle = a.getByName(ctx, query.Needles)
+	case query.By == aur.Provides:
+		iterFound, errNeedle = a.getByProvides(ctx, query)
+	default:
+		iterFound, errNeedle = a.gojqGetBatch(ctx, query)
+	}
+
 	if errNeedle != nil {
 		return nil, errNeedle
 	}
 
 	found = append(found, iterFound...)
 
-	return found, nil
+	return query.Paginate(found), nil
 }
 
 func (a *Client) gojqGetBatch(ctx context.Context, query *aur.Query) ([]aur.Pkg, error) {
-	pattern := ".[] | select("
+	bys, err := toSearchBy(query.By)
+	if err != nil {
+		return nil, err
+	}
+
+	pattern := "select("
+	vars := make(map[string]any, len(query.Needles))
 
 	for i, searchTerm := range query.Needles {
 		if i != 0 {
 			pattern += joiner
 		}
 
-		bys := toSearchBy(query.By)
+		// needles are passed as variables so the compiled query can be reused
+		needle := fmt.Sprintf("$needle%d", i)
+		vars[needle] = searchTerm
+
 		for j, by := range bys {
-			if query.Contains && query.By != aur.Provides {
-				pattern += fmt.Sprintf("(.%s // empty | test(%q))", by, searchTerm)
+			if query.Contains {
+				pattern += fmt.Sprintf("(.%s // empty | test(%s))", by, needle)
 			} else {
-				pattern += fmt.Sprintf("(.%s == %q)", by, searchTerm)
+				pattern += fmt.Sprintf("(.%s == %s)", by, needle)
 			}
 
 			if j != len(bys)-1 {
@@ -53,42 +77,57 @@ func (a *Client) gojqGetBatch(ctx context.Context, query *aur.Query) ([]aur.Pkg,
 	pattern += ")"
 
 	if a.debugLoggerFn != nil {
-		a.debugLoggerFn("AUR metadata query", pattern)
+		a.debugLoggerFn("AUR metadata query", pattern, query.Needles)
 	}
 
-	parsed, err := gojq.Parse(pattern)
+	names, values, err := queryVariables(vars)
 	if err != nil {
-		return nil, fmt.Errorf("unable to parse query: %w", err)
+		return nil, err
 	}
 
-	unmarshalledCache, errCache := a.cache(ctx)
+	code, err := a.compile(pattern, names)
+	if err != nil {
+		return nil, err
+	}
+
+	ds, errCache := a.cache(ctx)
 	if errCache != nil {
 		return nil, errCache
 	}
 
+	pkgs := ds.Pkgs
+
+	fields := searchFields(bys)
 	final := make([]aur.Pkg, 0, len(query.Needles))
-	iter := parsed.RunWithContext(ctx, unmarshalledCache) // or query.RunWithContext
 	dedup := make(map[string]bool)
+	window := query.Window()
 
-	for pkgMap, ok := iter.Next(); ok; pkgMap, ok = iter.Next() {
-		if err, ok := pkgMap.(error); ok {
-			return nil, err
+	for i := range pkgs {
+		if window != 0 && len(final) == window {
+			break
 		}
 
-		name := pkgMap.(map[string]interface{})["Name"].(string)
-		if dedup[name] {
+		pkg := &pkgs[i]
+		if dedup[pkg.Name] {
 			continue
 		}
 
-		dedup[name] = true
+		// the query is run on each package on its own so only the fields
+		// it looks at need to be converted
+		iter := code.RunWithContext(ctx, pkgToMap(pkg, fields), values...)
 
-		pkg := aur.Pkg{}
-		errU := mapstructure.Decode(pkgMap, &pkg)
-		if errU != nil {
-			return nil, fmt.Errorf("unable to decode aur package: %w: %+v", errU, pkgMap.(map[string]interface{}))
+		v, ok := iter.Next()
+		if !ok {
+			continue
 		}
 
-		final = append(final, pkg)
+		if err, ok := v.(error); ok {
+			return nil, err
+		}
+
+		dedup[pkg.Name] = true
+
+		final = append(final, *pkg)
 	}
 
 	if a.debugLoggerFn != nil {
@@ -98,39 +137,125 @@ func (a *Client) gojqGetBatch(ctx context.Context, query *aur.Query) ([]aur.Pkg,
 	return final, nil
 }
 
-func toSearchBy(by aur.By) []string {
+// getByName looks up packages by exact name using the name index. Results
+// are in dump order, not in the order of names.
+func (a *Client) getByName(ctx context.Context, names []string) ([]aur.Pkg, error) {
+	ds, err := a.cache(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := make([]int, 0, len(names))
+	dedup := make(map[string]bool, len(names))
+
+	for _, name := range names {
+		if dedup[name] {
+			continue
+		}
+
+		dedup[name] = true
+
+		if i, ok := ds.ByName[name]; ok {
+			indexes = append(indexes, i)
+		}
+	}
+
+	sort.Ints(indexes)
+
+	found := make([]aur.Pkg, 0, len(indexes))
+	for _, i := range indexes {
+		found = append(found, ds.Pkgs[i])
+	}
+
+	return found, nil
+}
+
+// getByProvides returns the packages satisfying the needles, parsed as
+// dependencies, by name or through their provides. Versions are honoured:
+// "libfoo" is satisfied by any package providing libfoo, "java-runtime>=17"
+// only by those providing a version of at least 17.
+func (a *Client) getByProvides(ctx context.Context, query *aur.Query) ([]aur.Pkg, error) {
+	ds, err := a.cache(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deps := make([]Dep, 0, len(query.Needles))
+	for _, needle := range query.Needles {
+		deps = append(deps, ParseDep(needle))
+	}
+
+	found := make([]aur.Pkg, 0, len(query.Needles))
+	dedup := make(map[string]bool)
+	window := query.Window()
+
+	for i := range ds.Pkgs {
+		if window != 0 && len(found) == window {
+			break
+		}
+
+		pkg := &ds.Pkgs[i]
+		if dedup[pkg.Name] {
+			continue
+		}
+
+		for _, dep := range deps {
+			if dep.SatisfiedByPkg(pkg.Name, pkg.Version, pkg.Provides) {
+				dedup[pkg.Name] = true
+				found = append(found, *pkg)
+
+				break
+			}
+		}
+	}
+
+	return found, nil
+}
+
+// searchFields returns the indexes of the package fields referenced by bys.
+func searchFields(bys []string) []int {
+	fields := make([]int, 0, len(bys))
+
+	for _, by := range bys {
+		fields = append(fields, pkgFieldIndex[strings.TrimSuffix(by, "[]?")])
+	}
+
+	return fields
+}
+
+func toSearchBy(by aur.By) ([]string, error) {
 	switch by {
 	case aur.Name:
-		return []string{"Name"}
+		return []string{"Name"}, nil
 	case aur.NameDesc:
-		return []string{"Name", "Description"}
+		return []string{"Name", "Description"}, nil
 	case aur.None:
-		return []string{"Name", "Description"}
+		return []string{"Name", "Description"}, nil
 	case aur.Provides:
-		return []string{"Name", "Provides[]?"}
+		return []string{"Name", "Provides[]?"}, nil
 	case aur.Maintainer:
-		return []string{"Maintainer"}
+		return []string{"Maintainer"}, nil
 	case aur.Submitter:
-		return []string{"Submitter"}
+		return []string{"Submitter"}, nil
 	case aur.Depends:
-		return []string{"Depends[]?"}
+		return []string{"Depends[]?"}, nil
 	case aur.MakeDepends:
-		return []string{"MakeDepends[]?"}
+		return []string{"MakeDepends[]?"}, nil
 	case aur.OptDepends:
-		return []string{"OptDepends[]?"}
+		return []string{"OptDepends[]?"}, nil
 	case aur.CheckDepends:
-		return []string{"CheckDepends[]?"}
+		return []string{"CheckDepends[]?"}, nil
 	case aur.Conflicts:
-		return []string{"Conflicts[]?"}
+		return []string{"Conflicts[]?"}, nil
 	case aur.Replaces:
-		return []string{"Replaces[]?"}
+		return []string{"Replaces[]?"}, nil
 	case aur.Keywords:
-		return []string{"Keywords[]?"}
+		return []string{"Keywords[]?"}, nil
 	case aur.Groups:
-		return []string{"Groups[]?"}
+		return []string{"Groups[]?"}, nil
 	case aur.CoMaintainers:
-		return []string{"CoMaintainers[]?"}
+		return []string{"CoMaintainers[]?"}, nil
 	default:
-		panic("invalid By")
+		return nil, fmt.Errorf("invalid By: %d", by)
 	}
 }
@@ -36,7 +36,7 @@ func TestGet(t *testing.T) {
 		desc          string
 		query         *aur.Query
 		expectedNames []string
-		wantPanic     bool
+		wantErr       bool
 	}
 
 	tests := []testcase{
@@ -234,23 +234,29 @@ func TestGet(t *testing.T) {
 			expectedNames: []string{"testpackage"},
 		},
 		{
-			desc: "Panic",
+			desc: "unset by",
+			query: &aur.Query{
+				Needles: []string{"yay", "yay-bin"},
+			},
+			expectedNames: []string{"yay", "yay-bin"},
+		},
+		{
+			desc: "unsupported by",
 			query: &aur.Query{
 				By:       -10, // unsupported
 				Needles:  []string{"prep"},
 				Contains: true,
 			},
-			expectedNames: []string{""},
-			wantPanic:     true,
+			wantErr: true,
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.desc, func(t *testing.T) {
-			if test.wantPanic {
-				assert.Panics(t, func() {
-					client.Get(ctx, test.query)
-				})
+			if test.wantErr {
+				_, err := client.Get(ctx, test.query)
+				assert.ErrorContains(t, err, "invalid By")
+
 				return
 			}
 
@@ -267,3 +273,98 @@ func TestGet(t *testing.T) {
 		})
 	}
 }
+
+func TestGetSortedPage(t *testing.T) {
+	t.Parallel()
+
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	pkgs, err := client.Get(ctx, &aur.Query{
+		By:         aur.Name,
+		Needles:    []string{"yay", "jack"},
+		Contains:   true,
+		SortBy:     aur.SortVotes,
+		Descending: true,
+		Limit:      2,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"yay", "yay-bin"}, []string{pkgs[0].Name, pkgs[1].Name})
+
+	pkgs, err = client.Get(ctx, &aur.Query{
+		By:       aur.Name,
+		Needles:  []string{"jack-audio-tools"},
+		Contains: true,
+		Offset:   3,
+		Limit:    5,
+	})
+	require.NoError(t, err)
+	require.Len(t, pkgs, 2)
+	assert.Equal(t, "jack-audio-tools-carla", pkgs[0].Name)
+
+	pkgs, err = client.Get(ctx, &aur.Query{
+		By:      aur.Name,
+		Needles: []string{"yay-git", "yay", "yay-bin"},
+		SortBy:  aur.SortName,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "yay", pkgs[0].Name)
+	assert.Equal(t, "yay-git", pkgs[2].Name)
+}
+
+func TestGetByNameDumpOrder(t *testing.T) {
+	t.Parallel()
+
+	client := newTestClient(t)
+
+	pkgs, err := client.Get(context.Background(), &aur.Query{
+		By:      aur.Name,
+		Needles: []string{"testpackage", "yay-git", "missing", "yay", "liquidsfz-git", "yay"},
+	})
+	require.NoError(t, err)
+
+	names := []string{}
+	for i := range pkgs {
+		names = append(names, pkgs[i].Name)
+	}
+
+	assert.Equal(t, []string{"liquidsfz-git", "yay", "yay-git", "testpackage"}, names)
+}
+
+func TestGetProvidesVersions(t *testing.T) {
+	t.Parallel()
+
+	client := newDatasetClient(t, []aur.Pkg{
+		{Name: "jre17-openjdk", Version: "17.0.5.u8-1", Provides: []string{"java-runtime=17", "jre17"}},
+		{Name: "jre11-openjdk", Version: "11.0.17.u8-1", Provides: []string{"java-runtime=11"}},
+		{Name: "java-runtime-dummy", Version: "1-1", Provides: []string{"java-runtime"}},
+		{Name: "libfoo-git", Version: "1.2.r3-1", Provides: []string{"libfoo=1.2-1"}},
+	})
+	ctx := context.Background()
+
+	testCases := []struct {
+		needles []string
+		want    []string
+	}{
+		{[]string{"java-runtime"}, []string{"jre17-openjdk", "jre11-openjdk", "java-runtime-dummy"}},
+		{[]string{"java-runtime>=17"}, []string{"jre17-openjdk"}},
+		{[]string{"java-runtime<17"}, []string{"jre11-openjdk"}},
+		{[]string{"libfoo"}, []string{"libfoo-git"}},
+		{[]string{"libfoo=1.2"}, []string{"libfoo-git"}},
+		{[]string{"libfoo>1.2"}, []string{}},
+		{[]string{"jre17-openjdk>=17"}, []string{"jre17-openjdk"}},
+		{[]string{"jre17", "java-runtime=11"}, []string{"jre17-openjdk", "jre11-openjdk"}},
+	}
+
+	for _, tc := range testCases {
+		pkgs, err := client.Get(ctx, &aur.Query{By: aur.Provides, Needles: tc.needles})
+		require.NoError(t, err)
+
+		names := make([]string, 0, len(pkgs))
+		for i := range pkgs {
+			names = append(names, pkgs[i].Name)
+		}
+
+		assert.Equal(t, tc.want, names, tc.needles)
+	}
+}
@@ -0,0 +1,202 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+const (
+	// refreshRetryInterval is the longest wait after a failed background
+	// refresh.
+	refreshRetryInterval = time.Minute
+	// minRefreshInterval is the shortest wait between background refreshes,
+	// so an expired cache that can't be updated, as when offline, doesn't
+	// make the refresher spin.
+	minRefreshInterval = time.Second
+)
+
+// ErrAutoRefreshRunning is returned when starting an already running refresher.
+var ErrAutoRefreshRunning = errors.New("auto refresh already running")
+
+// RefreshErrorFn is called when a background refresh fails.
+type RefreshErrorFn func(err error)
+
+type refresher struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// StartAutoRefresh starts a goroutine keeping the in-memory data up to date.
+// The metadata is downloaded again every time the cache expires, waiting an
+// additional random jitter so clients sharing a schedule don't refresh at
+// once. If another process updates the cache file it is reloaded instead.
+// Refreshes are at least a second apart, even when the cache stays expired.
+//
+// Readers are never blocked by a refresh, they keep seeing the previous data
+// until the new data is swapped in. Errors are reported to the function set
+// with WithRefreshErrorHandler and the refresh is retried.
+//
+// The refresher runs until ctx is canceled or StopAutoRefresh is called.
+func (a *Client) StartAutoRefresh(ctx context.Context) error {
+	a.refresherMu.Lock()
+	defer a.refresherMu.Unlock()
+
+	if a.static {
+		return ErrNoSource
+	}
+
+	if a.refresher != nil {
+		return ErrAutoRefreshRunning
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	r := &refresher{cancel: cancel, done: make(chan struct{})}
+	a.refresher = r
+
+	go a.autoRefresh(ctx, r.done)
+
+	return nil
+}
+
+// StopAutoRefresh stops the refresher started by StartAutoRefresh, aborting
+// a refresh in progress, and waits for it to exit.
+func (a *Client) StopAutoRefresh() {
+	a.refresherMu.Lock()
+	r := a.refresher
+	a.refresher = nil
+	a.refresherMu.Unlock()
+
+	if r == nil {
+		return
+	}
+
+	r.cancel()
+	<-r.done
+}
+
+func (a *Client) autoRefresh(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano())) //nolint:gosec // jitter only
+	failed := false
+
+	for {
+		wait := a.refreshWait(failed)
+
+		if a.refreshJitter > 0 {
+			wait += time.Duration(rnd.Int63n(int64(a.refreshJitter)))
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return
+		case <-timer.C:
+		}
+
+		err := a.refreshIfNeeded(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		failed = err != nil
+		if failed {
+			if a.debugLoggerFn != nil {
+				a.debugLoggerFn("AUR metadata background refresh failed", err)
+			}
+
+			if a.refreshErrorFn != nil {
+				a.refreshErrorFn(err)
+			}
+		}
+	}
+}
+
+// refreshIfNeeded downloads the metadata if the cache expired, or reloads
+// the cache file if it was updated since it was loaded.
+func (a *Client) refreshIfNeeded(ctx context.Context) error {
+	// deferred first to run once the lock is released
+	defer a.deliverChanges()
+
+	a.updateMu.Lock()
+	defer a.updateMu.Unlock()
+
+	update, err := a.needsUpdate()
+	if err != nil {
+		return err
+	}
+
+	if update && !a.offline {
+		_, err = a.refresh(ctx)
+
+		return err
+	}
+
+	prev := a.unmarshalledCache.Load()
+	if prev != nil && prev.modTime.Equal(a.cacheModTime()) {
+		return nil
+	}
+
+	ds, err := a.loadCache()
+	if errors.Is(err, ErrCorruptCache) {
+		_, err = a.heal(ctx, err)
+
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+
+	a.unmarshalledCache.Store(ds)
+	a.record(ds)
+	a.notifyChanges(prev, ds)
+
+	return nil
+}
+
+// refreshWait returns how long to wait before the next background refresh,
+// without jitter.
+func (a *Client) refreshWait(failed bool) time.Duration {
+	wait := a.untilExpiry()
+	if failed && wait < a.retryInterval() {
+		wait = a.retryInterval()
+	}
+
+	if wait < minRefreshInterval {
+		wait = minRefreshInterval
+	}
+
+	return wait
+}
+
+// untilExpiry returns how long the cache file stays valid.
+func (a *Client) untilExpiry() time.Duration {
+	modTime := a.cacheModTime()
+	if modTime.IsZero() {
+		return 0
+	}
+
+	wait := time.Until(modTime.Add(a.cacheValidity))
+	if wait < 0 {
+		return 0
+	}
+
+	return wait
+}
+
+// retryInterval returns how long to wait after a failed refresh.
+func (a *Client) retryInterval() time.Duration {
+	switch {
+	case a.cacheValidity < minRefreshInterval:
+		return minRefreshInterval
+	case a.cacheValidity < refreshRetryInterval:
+		return a.cacheValidity
+	default:
+		return refreshRetryInterval
+	}
+}
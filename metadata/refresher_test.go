@@ -0,0 +1,194 @@
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Jguer/aur"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncMockHTTP is a MockHTTP that can be changed while in use.
+type syncMockHTTP struct {
+	mu            sync.Mutex
+	bytesToReturn []byte
+	statusCode    int
+	calls         atomic.Int32
+}
+
+func (m *syncMockHTTP) set(statusCode int, b []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.statusCode = statusCode
+	m.bytesToReturn = b
+}
+
+func (m *syncMockHTTP) Do(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls.Add(1)
+
+	return &http.Response{
+		StatusCode: m.statusCode,
+		Status:     http.StatusText(m.statusCode),
+		Body:       io.NopCloser(bytes.NewReader(m.bytesToReturn)),
+	}, nil
+}
+
+func yayVersion(t *testing.T, client *Client) string {
+	t.Helper()
+
+	pkgs, err := client.Get(context.Background(), &aur.Query{By: aur.Name, Needles: []string{"yay"}})
+	require.NoError(t, err)
+	require.Len(t, pkgs, 1)
+
+	return pkgs[0].Version
+}
+
+func TestClientAutoRefresh(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	testBytes, err := os.ReadFile("test.json")
+	require.NoError(t, err)
+
+	pkgs, err := DecodePkgs(bytes.NewReader(testBytes))
+	require.NoError(t, err)
+	pkgs[3].Version = "12.0.0-1"
+	updatedBytes, err := json.Marshal(pkgs)
+	require.NoError(t, err)
+
+	mock := &syncMockHTTP{}
+	mock.set(http.StatusOK, testBytes)
+
+	errs := make(chan error, 10)
+	changes := make(chan []Change, 10)
+
+	client, err := New(
+		WithCacheFilePath(t.TempDir()+"/cache.json"),
+		WithHTTPClient(mock),
+		WithCustomCacheValidity(100*time.Millisecond),
+		WithRefreshJitter(10*time.Millisecond),
+		WithRefreshErrorHandler(func(err error) { errs <- err }),
+		WithChangeHandler(func(c []Change) { changes <- c }),
+		WithDownloadRetries(0, 0),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, "11.3.0-1", yayVersion(t, client))
+
+	require.NoError(t, client.StartAutoRefresh(ctx))
+	assert.ErrorIs(t, client.StartAutoRefresh(ctx), ErrAutoRefreshRunning)
+
+	mock.set(http.StatusOK, updatedBytes)
+
+	select {
+	case c := <-changes:
+		require.Len(t, c, 1)
+		assert.Equal(t, "yay", c[0].Name)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no refresh")
+	}
+
+	assert.Equal(t, "12.0.0-1", yayVersion(t, client))
+
+	mock.set(http.StatusInternalServerError, nil)
+
+	select {
+	case err := <-errs:
+		assert.ErrorContains(t, err, "failed to download metadata")
+	case <-time.After(5 * time.Second):
+		t.Fatal("no error reported")
+	}
+
+	// readers keep the last good data
+	assert.Equal(t, "12.0.0-1", yayVersion(t, client))
+
+	client.StopAutoRefresh()
+	client.StopAutoRefresh()
+
+	calls := mock.calls.Load()
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, calls, mock.calls.Load())
+}
+
+func TestClientAutoRefreshReloadsUpdatedFile(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	cacheFilePath := t.TempDir() + "/cache.json"
+
+	testBytes, err := os.ReadFile("test.json")
+	require.NoError(t, err)
+
+	pkgs, err := DecodePkgs(bytes.NewReader(testBytes))
+	require.NoError(t, err)
+	pkgs[3].Version = "12.0.0-1"
+	updatedBytes, err := json.Marshal(pkgs)
+	require.NoError(t, err)
+
+	mock := &syncMockHTTP{}
+	mock.set(http.StatusOK, testBytes)
+
+	client, err := New(
+		WithCacheFilePath(cacheFilePath),
+		WithHTTPClient(mock),
+		WithRefreshJitter(0),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "11.3.0-1", yayVersion(t, client))
+
+	// another process updated the cache file
+	require.NoError(t, writeCache(cacheFilePath, bytes.NewReader(updatedBytes)))
+	future := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(cacheFilePath, future, future))
+
+	require.NoError(t, client.refreshIfNeeded(ctx))
+	assert.Equal(t, "12.0.0-1", yayVersion(t, client))
+	assert.Equal(t, int32(1), mock.calls.Load())
+}
+
+func TestClientAutoRefreshStopsWithContext(t *testing.T) {
+	t.Parallel()
+
+	client, err := New(WithCacheFilePath(t.TempDir() + "/cache.json"))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	require.NoError(t, client.StartAutoRefresh(ctx))
+	client.StopAutoRefresh()
+
+	_, err = New(WithRefreshJitter(-1))
+	assert.Error(t, err)
+}
+
+func TestClientRefreshWait(t *testing.T) {
+	t.Parallel()
+
+	// expired caches and caches without validity are not refreshed in a loop
+	for _, validity := range []time.Duration{0, time.Millisecond} {
+		client, err := New(WithCacheFilePath(t.TempDir()+"/cache.json"), WithCustomCacheValidity(validity))
+		require.NoError(t, err)
+
+		assert.Equal(t, minRefreshInterval, client.refreshWait(false), validity)
+		assert.Equal(t, minRefreshInterval, client.refreshWait(true), validity)
+	}
+
+	client, err := New(WithCacheFilePath(t.TempDir()+"/cache.json"), WithCustomCacheValidity(time.Hour))
+	require.NoError(t, err)
+
+	assert.Equal(t, minRefreshInterval, client.refreshWait(false))
+	assert.Equal(t, refreshRetryInterval, client.refreshWait(true))
+}
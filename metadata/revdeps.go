@@ -0,0 +1,209 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Jguer/aur"
+)
+
+// Dependent is a package depending on another one.
+type Dependent struct {
+	Pkg aur.Pkg `json:"Pkg"`
+	// Dep is the dependency as declared by Pkg, e.g. "libfoo>=1.2".
+	Dep string `json:"Dep"`
+	// Target is the package the dependency resolves to: the queried package
+	// for direct dependents, a dependent of the previous depth otherwise.
+	Target string `json:"Target"`
+	// Depth is 1 for direct dependents.
+	Depth int `json:"Depth"`
+}
+
+// ReverseDeps holds the dependents of a package grouped by relation.
+type ReverseDeps struct {
+	Depends      []Dependent `json:"Depends"`
+	MakeDepends  []Dependent `json:"MakeDepends"`
+	CheckDepends []Dependent `json:"CheckDepends"`
+	OptDepends   []Dependent `json:"OptDepends"`
+}
+
+// Len returns the number of dependents in all relations.
+func (r *ReverseDeps) Len() int {
+	return len(r.Depends) + len(r.MakeDepends) + len(r.CheckDepends) + len(r.OptDepends)
+}
+
+func (r *ReverseDeps) add(relation aur.By, d *Dependent) {
+	switch relation {
+	case aur.Depends:
+		r.Depends = append(r.Depends, *d)
+	case aur.MakeDepends:
+		r.MakeDepends = append(r.MakeDepends, *d)
+	case aur.CheckDepends:
+		r.CheckDepends = append(r.CheckDepends, *d)
+	case aur.OptDepends:
+		r.OptDepends = append(r.OptDepends, *d)
+	}
+}
+
+// ReverseDepsOptions configures ReverseDeps.
+type ReverseDepsOptions struct {
+	// Relations are the dependency relations to follow, by default
+	// aur.Depends, aur.MakeDepends, aur.CheckDepends and aur.OptDepends.
+	Relations []aur.By
+	// MaxDepth limits transitive lookups. The default of 1 only returns direct
+	// dependents, a negative value follows dependents without limit.
+	MaxDepth int
+}
+
+var depRelations = []aur.By{aur.Depends, aur.MakeDepends, aur.CheckDepends, aur.OptDepends}
+
+// revDepEdge is a dependency of the package at index pkg.
+type revDepEdge struct {
+	pkg      int32
+	relation aur.By
+	dep      string
+}
+
+func buildRevDepIndex(ds *dataset) map[string][]revDepEdge {
+	index := map[string][]revDepEdge{}
+
+	for i := range ds.Pkgs {
+		pkg := &ds.Pkgs[i]
+		if ds.ByName[pkg.Name] != i {
+			continue // duplicate
+		}
+
+		for _, relation := range depRelations {
+			for _, dep := range pkgDeps(pkg, relation) {
+				name := ParseDep(dep).Name
+				index[name] = append(index[name], revDepEdge{pkg: int32(i), relation: relation, dep: dep})
+			}
+		}
+	}
+
+	return index
+}
+
+func isDepRelation(by aur.By) bool {
+	for _, relation := range depRelations {
+		if by == relation {
+			return true
+		}
+	}
+
+	return false
+}
+
+func pkgDeps(pkg *aur.Pkg, relation aur.By) []string {
+	switch relation {
+	case aur.Depends:
+		return pkg.Depends
+	case aur.MakeDepends:
+		return pkg.MakeDepends
+	case aur.CheckDepends:
+		return pkg.CheckDepends
+	case aur.OptDepends:
+		return pkg.OptDepends
+	default:
+		return nil
+	}
+}
+
+// ReverseDeps returns the AUR packages depending on name, either directly or
+// through one of its provisions. Versioned dependencies only count if
+// satisfied by the version of the package or provision. If name is not an
+// AUR package, e.g. a repository package, dependencies on it are matched by
+// name only. opts may be nil.
+func (a *Client) ReverseDeps(ctx context.Context, name string, opts *ReverseDepsOptions) (*ReverseDeps, error) {
+	if opts == nil {
+		opts = &ReverseDepsOptions{}
+	}
+
+	relations := map[aur.By]bool{}
+
+	for _, relation := range opts.Relations {
+		if !isDepRelation(relation) {
+			return nil, fmt.Errorf("invalid dependency relation %d", relation)
+		}
+
+		relations[relation] = true
+	}
+
+	if len(relations) == 0 {
+		for _, relation := range depRelations {
+			relations[relation] = true
+		}
+	}
+
+	maxDepth := opts.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = 1
+	}
+
+	ds, err := a.cache(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	index := ds.revDepIndex()
+	result := &ReverseDeps{}
+	reported := map[aur.By]map[string]bool{}
+	expanded := map[string]bool{name: true}
+
+	for relation := range relations {
+		reported[relation] = map[string]bool{}
+	}
+
+	type target struct {
+		name     string
+		pkg      *aur.Pkg // nil if not in the AUR
+		provides []string
+	}
+
+	frontier := []target{{name: name, pkg: ds.lookup(name)}}
+	if frontier[0].pkg != nil {
+		frontier[0].provides = frontier[0].pkg.Provides
+	}
+
+	for depth := 1; len(frontier) != 0 && (maxDepth < 0 || depth <= maxDepth); depth++ {
+		next := []target{}
+
+		for _, t := range frontier {
+			keys := []string{t.name}
+			for _, provide := range t.provides {
+				if p := ParseDep(provide).Name; p != t.name {
+					keys = append(keys, p)
+				}
+			}
+
+			for _, key := range keys {
+				for _, edge := range index[key] {
+					if !relations[edge.relation] {
+						continue
+					}
+
+					dependent := &ds.Pkgs[edge.pkg]
+					if dependent.Name == name || reported[edge.relation][dependent.Name] {
+						continue
+					}
+
+					if t.pkg != nil && !ParseDep(edge.dep).SatisfiedByPkg(t.pkg.Name, t.pkg.Version, t.provides) {
+						continue
+					}
+
+					reported[edge.relation][dependent.Name] = true
+					result.add(edge.relation, &Dependent{Pkg: *dependent, Dep: edge.dep, Target: t.name, Depth: depth})
+
+					if !expanded[dependent.Name] {
+						expanded[dependent.Name] = true
+						next = append(next, target{name: dependent.Name, pkg: dependent, provides: dependent.Provides})
+					}
+				}
+			}
+		}
+
+		frontier = next
+	}
+
+	return result, nil
+}
@@ -0,0 +1,97 @@
+package metadata
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Jguer/aur"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDatasetClient(t *testing.T, pkgs []aur.Pkg) *Client {
+	t.Helper()
+
+	client, err := New(WithCacheFilePath(t.TempDir() + "/cache.json"))
+	require.NoError(t, err)
+
+	client.unmarshalledCache.Store(newDataset(pkgs))
+
+	return client
+}
+
+func dependentNames(deps []Dependent) []string {
+	names := []string{}
+	for i := range deps {
+		names = append(names, deps[i].Pkg.Name)
+	}
+
+	return names
+}
+
+func TestClientReverseDeps(t *testing.T) {
+	t.Parallel()
+
+	client := newDatasetClient(t, []aur.Pkg{
+		{Name: "libfoo", Version: "1.5-1", Provides: []string{"libfoo.so=1-64", "foo-api"}},
+		{Name: "app", Version: "1", Depends: []string{"libfoo>=1.2"}, MakeDepends: []string{"libfoo"}},
+		{Name: "app-old", Version: "1", Depends: []string{"libfoo<1"}},
+		{Name: "app-so", Version: "1", Depends: []string{"libfoo.so=1-64"}},
+		{Name: "app-api", Version: "1", Depends: []string{"foo-api>=2"}}, // unversioned provide
+		{Name: "plugin", Version: "1", Depends: []string{"app"}, CheckDepends: []string{"libfoo"}},
+		{Name: "extra", Version: "1", OptDepends: []string{"plugin: for the plugin"}},
+		{Name: "user", Version: "1", Depends: []string{"glibc"}},
+	})
+	ctx := context.Background()
+
+	deps, err := client.ReverseDeps(ctx, "libfoo", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app", "app-so"}, dependentNames(deps.Depends))
+	assert.Equal(t, []string{"app"}, dependentNames(deps.MakeDepends))
+	assert.Equal(t, []string{"plugin"}, dependentNames(deps.CheckDepends))
+	assert.Empty(t, deps.OptDepends)
+	assert.Equal(t, "libfoo>=1.2", deps.Depends[0].Dep)
+	assert.Equal(t, 4, deps.Len())
+
+	deps, err = client.ReverseDeps(ctx, "libfoo", &ReverseDepsOptions{
+		Relations: []aur.By{aur.Depends, aur.OptDepends},
+		MaxDepth:  -1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app", "app-so", "plugin"}, dependentNames(deps.Depends))
+	assert.Equal(t, []string{"extra"}, dependentNames(deps.OptDepends))
+	assert.Equal(t, 2, deps.Depends[2].Depth)
+	assert.Equal(t, "app", deps.Depends[2].Target)
+	assert.Equal(t, 3, deps.OptDepends[0].Depth)
+
+	deps, err = client.ReverseDeps(ctx, "libfoo", &ReverseDepsOptions{
+		Relations: []aur.By{aur.Depends, aur.OptDepends},
+		MaxDepth:  2,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app", "app-so", "plugin"}, dependentNames(deps.Depends))
+	assert.Empty(t, deps.OptDepends)
+
+	// not an AUR package, matched by name
+	deps, err = client.ReverseDeps(ctx, "glibc", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user"}, dependentNames(deps.Depends))
+
+	_, err = client.ReverseDeps(ctx, "libfoo", &ReverseDepsOptions{Relations: []aur.By{aur.Provides}})
+	assert.Error(t, err)
+}
+
+func TestClientReverseDepsTestData(t *testing.T) {
+	t.Parallel()
+
+	client := newTestClient(t)
+
+	deps, err := client.ReverseDeps(context.Background(), "kmod", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"linux-amd-git", "linux-ath-dfs"}, dependentNames(deps.Depends))
+
+	deps, err = client.ReverseDeps(context.Background(), "libjack.so", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"liquidsfz-git"}, dependentNames(deps.MakeDepends))
+	assert.Equal(t, []string{"liquidsfz-git"}, dependentNames(deps.OptDepends))
+}
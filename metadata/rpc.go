@@ -0,0 +1,177 @@
+package metadata
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/Jguer/aur"
+)
+
+// Limits and error messages of the aurweb RPC, which Search and Info mimic.
+const (
+	minSearchLength  = 2
+	maxSearchResults = 5000
+
+	errMsgArgTooSmall  = "Query arg too small."
+	errMsgTooMany      = "Too many package results."
+	errMsgNoSearchData = "No request type/data specified."
+)
+
+// rpcError returns an error as the rpc client reports an aurweb error,
+// which is sent with a 200 status.
+func rpcError(msg string) error {
+	return &aur.PayloadError{StatusCode: http.StatusOK, ErrorField: msg}
+}
+
+// Search searches the packages like the aurweb RPC, so a Client can be used
+// in place of an rpc.Client.
+//
+// Name and name-desc, the default for By.None, match packages containing
+// query in their name or description, ignoring case, and require at least
+// two characters. The other fields must match query exactly, dependencies by
+// name only. Searching by maintainer with an empty query returns orphans.
+// Like aurweb, results only carry the basic package fields and
+// too many results are an error.
+func (a *Client) Search(ctx context.Context, query string, by aur.By) ([]aur.Pkg, error) {
+	if by == aur.None {
+		by = aur.NameDesc
+	}
+
+	match, err := rpcMatcher(query, by)
+	if err != nil {
+		return nil, err
+	}
+
+	ds, err := a.cache(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	found := []aur.Pkg{}
+
+	for i := range ds.Pkgs {
+		if !match(&ds.Pkgs[i]) {
+			continue
+		}
+
+		if len(found) == maxSearchResults {
+			return nil, rpcError(errMsgTooMany)
+		}
+
+		found = append(found, searchResult(&ds.Pkgs[i]))
+	}
+
+	return found, nil
+}
+
+// Info returns the packages called names, skipping unknown ones.
+func (a *Client) Info(ctx context.Context, names []string) ([]aur.Pkg, error) {
+	return a.getByName(ctx, names)
+}
+
+// rpcMatcher returns the aurweb matching rule for query and by.
+func rpcMatcher(query string, by aur.By) (func(pkg *aur.Pkg) bool, error) {
+	switch by {
+	case aur.Name, aur.NameDesc:
+		if len(query) < minSearchLength {
+			return nil, rpcError(errMsgArgTooSmall)
+		}
+
+		needle := strings.ToLower(query)
+
+		return func(pkg *aur.Pkg) bool {
+			return strings.Contains(strings.ToLower(pkg.Name), needle) ||
+				(by == aur.NameDesc && strings.Contains(strings.ToLower(pkg.Description), needle))
+		}, nil
+	case aur.Maintainer:
+		return func(pkg *aur.Pkg) bool { return strings.EqualFold(pkg.Maintainer, query) }, nil
+	}
+
+	if query == "" {
+		return nil, rpcError(errMsgNoSearchData)
+	}
+
+	switch by {
+	case aur.Submitter:
+		return func(pkg *aur.Pkg) bool { return strings.EqualFold(pkg.Submitter, query) }, nil
+	case aur.Depends:
+		return depMatcher(query, func(pkg *aur.Pkg) []string { return pkg.Depends }), nil
+	case aur.MakeDepends:
+		return depMatcher(query, func(pkg *aur.Pkg) []string { return pkg.MakeDepends }), nil
+	case aur.OptDepends:
+		return depMatcher(query, func(pkg *aur.Pkg) []string { return pkg.OptDepends }), nil
+	case aur.CheckDepends:
+		return depMatcher(query, func(pkg *aur.Pkg) []string { return pkg.CheckDepends }), nil
+	case aur.Provides:
+		return depMatcher(query, func(pkg *aur.Pkg) []string { return pkg.Provides }), nil
+	case aur.Conflicts:
+		return depMatcher(query, func(pkg *aur.Pkg) []string { return pkg.Conflicts }), nil
+	case aur.Replaces:
+		return depMatcher(query, func(pkg *aur.Pkg) []string { return pkg.Replaces }), nil
+	case aur.Keywords:
+		// any of the space separated keywords
+		keywords := strings.Fields(query)
+
+		return func(pkg *aur.Pkg) bool {
+			for _, keyword := range keywords {
+				if containsFold(pkg.Keywords, keyword) {
+					return true
+				}
+			}
+
+			return false
+		}, nil
+	case aur.Groups:
+		return func(pkg *aur.Pkg) bool { return containsFold(pkg.Groups, query) }, nil
+	case aur.CoMaintainers:
+		return func(pkg *aur.Pkg) bool { return containsFold(pkg.CoMaintainers, query) }, nil
+	default:
+		panic("invalid By")
+	}
+}
+
+// depMatcher matches packages with a relation to query, ignoring versions
+// and optional dependency descriptions.
+func depMatcher(query string, relations func(pkg *aur.Pkg) []string) func(pkg *aur.Pkg) bool {
+	return func(pkg *aur.Pkg) bool {
+		for _, rel := range relations(pkg) {
+			if strings.EqualFold(ParseDep(rel).Name, query) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// searchResult returns the fields of pkg aurweb includes in search results.
+func searchResult(pkg *aur.Pkg) aur.Pkg {
+	return aur.Pkg{
+		ID:             pkg.ID,
+		Name:           pkg.Name,
+		PackageBaseID:  pkg.PackageBaseID,
+		PackageBase:    pkg.PackageBase,
+		Version:        pkg.Version,
+		Description:    pkg.Description,
+		URL:            pkg.URL,
+		NumVotes:       pkg.NumVotes,
+		Popularity:     pkg.Popularity,
+		OutOfDate:      pkg.OutOfDate,
+		Maintainer:     pkg.Maintainer,
+		Submitter:      pkg.Submitter,
+		FirstSubmitted: pkg.FirstSubmitted,
+		LastModified:   pkg.LastModified,
+		URLPath:        pkg.URLPath,
+	}
+}
@@ -0,0 +1,50 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Jguer/aur"
+	"github.com/Jguer/aur/internal/conformance"
+	"github.com/Jguer/aur/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ rpc.ClientInterface = (*Client)(nil)
+
+func TestClientConformance(t *testing.T) {
+	t.Parallel()
+
+	conformance.Run(t, func(t *testing.T, pkgs []aur.Pkg) conformance.Client {
+		return newDatasetClient(t, pkgs)
+	})
+}
+
+func TestClientSearchErrors(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	pkgs := make([]aur.Pkg, 0, maxSearchResults+1)
+	for i := 0; i <= maxSearchResults; i++ {
+		pkgs = append(pkgs, aur.Pkg{Name: fmt.Sprintf("python-%d", i)})
+	}
+
+	client := newDatasetClient(t, pkgs)
+
+	var payloadErr *aur.PayloadError
+
+	_, err := client.Search(ctx, "python", aur.Name)
+	require.True(t, errors.As(err, &payloadErr), err)
+	assert.Equal(t, "Too many package results.", payloadErr.ErrorField)
+
+	_, err = client.Search(ctx, "", aur.Depends)
+	require.True(t, errors.As(err, &payloadErr), err)
+	assert.Equal(t, "No request type/data specified.", payloadErr.ErrorField)
+
+	found, err := client.Search(ctx, "python-499", aur.Name)
+	require.NoError(t, err)
+	assert.Len(t, found, 11) // python-499 and python-4990 to python-4999
+}
@@ -0,0 +1,286 @@
+package metadata
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Jguer/aur"
+	"github.com/Jguer/aur/internal/curve"
+)
+
+// RankWeights tunes how RankedSearch scores packages.
+type RankWeights struct {
+	// Name weighs the similarity of the package name to the query,
+	// tolerant to typos.
+	Name float64
+	// Text weighs the share of query words found in the description.
+	Text float64
+	// Keywords weighs the share of query words found in the keywords.
+	Keywords float64
+	// Votes and Popularity boost relevant packages by their votes and
+	// popularity. The boost saturates, so they mostly break ties.
+	Votes      float64
+	Popularity float64
+}
+
+// DefaultRankWeights are used when RankOptions.Weights is nil.
+var DefaultRankWeights = RankWeights{
+	Name:       1,
+	Text:       0.4,
+	Keywords:   0.4,
+	Votes:      0.15,
+	Popularity: 0.15,
+}
+
+const (
+	// defaultMinRelevance is the minimum relevance (name similarity or share of
+	// words matched) a package needs to be returned.
+	defaultMinRelevance = 0.3
+	// votesHalf and popularityHalf are the values at which half of the
+	// respective boost is given.
+	votesHalf      = 50
+	popularityHalf = 1
+)
+
+// RankOptions configures RankedSearch.
+type RankOptions struct {
+	Weights *RankWeights
+	// Limit is the maximum number of results, 0 means no limit.
+	Limit int
+	// MinRelevance overrides the minimum relevance when non zero.
+	MinRelevance float64
+}
+
+// SearchResult is a package found by a ranked search.
+type SearchResult struct {
+	Pkg   aur.Pkg `json:"Pkg"`
+	Score float64 `json:"Score"`
+}
+
+// RankedSearch finds packages relevant to query, best first. Package names
+// are compared allowing for typos, so the top result for a misspelled
+// name is a good "did you mean" suggestion. opts may be nil.
+func (a *Client) RankedSearch(ctx context.Context, query string, opts *RankOptions) ([]SearchResult, error) {
+	if opts == nil {
+		opts = &RankOptions{}
+	}
+
+	weights := opts.Weights
+	if weights == nil {
+		weights = &DefaultRankWeights
+	}
+
+	minRelevance := opts.MinRelevance
+	if minRelevance == 0 {
+		minRelevance = defaultMinRelevance
+	}
+
+	ds, err := a.cache(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q := newRankQuery(query)
+	if q.name == "" {
+		return []SearchResult{}, nil
+	}
+
+	results := []SearchResult{}
+
+	for i := range ds.Pkgs {
+		if i%4096 == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		pkg := &ds.Pkgs[i]
+
+		nameSim := q.nameSimilarity(pkg.Name)
+		textMatch := q.wordsMatched(tokenize(pkg.Description))
+		keywordMatch := q.wordsMatched(lowerAll(pkg.Keywords))
+
+		if nameSim < minRelevance && textMatch < minRelevance && keywordMatch < minRelevance {
+			continue
+		}
+
+		score := weights.Name*nameSim +
+			weights.Text*textMatch +
+			weights.Keywords*keywordMatch +
+			weights.Votes*curve.Saturate(float64(pkg.NumVotes), votesHalf) +
+			weights.Popularity*curve.Saturate(pkg.Popularity, popularityHalf)
+
+		results = append(results, SearchResult{Pkg: *pkg, Score: score})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+
+		return results[i].Pkg.Name < results[j].Pkg.Name
+	})
+
+	if opts.Limit > 0 && len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+
+	return results, nil
+}
+
+type rankQuery struct {
+	name     string
+	trigrams map[string]bool
+	words    []string
+}
+
+func newRankQuery(query string) *rankQuery {
+	name := strings.ToLower(strings.TrimSpace(query))
+
+	return &rankQuery{
+		name:     name,
+		trigrams: trigrams(name),
+		words:    tokenize(query),
+	}
+}
+
+// nameSimilarity returns how alike name is to the query between 0 and 1,
+// the best of trigram similarity, edit distance and substring matching.
+func (q *rankQuery) nameSimilarity(name string) float64 {
+	name = strings.ToLower(name)
+	if name == q.name {
+		return 1
+	}
+
+	sim := jaccard(q.trigrams, trigrams(name))
+
+	longest := len([]rune(name))
+	if l := len([]rune(q.name)); l > longest {
+		longest = l
+	}
+
+	if editSim := 1 - float64(levenshtein(q.name, name, false))/float64(longest); editSim > sim {
+		sim = editSim
+	}
+
+	if strings.Contains(name, q.name) {
+		// partial credit growing with the covered share of the name
+		if subSim := 0.6 + 0.3*float64(len(q.name))/float64(len(name)); subSim > sim {
+			sim = subSim
+		}
+	}
+
+	return sim
+}
+
+// wordsMatched returns the share of query words found in words.
+func (q *rankQuery) wordsMatched(words []string) float64 {
+	if len(q.words) == 0 || len(words) == 0 {
+		return 0
+	}
+
+	matched := 0
+
+	for _, qw := range q.words {
+		for _, w := range words {
+			if w == qw {
+				matched++
+
+				break
+			}
+		}
+	}
+
+	return float64(matched) / float64(len(q.words))
+}
+
+// tokenize splits s into lower case words of letters and digits.
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func lowerAll(values []string) []string {
+	lowered := make([]string, len(values))
+	for i := range values {
+		lowered[i] = strings.ToLower(values[i])
+	}
+
+	return lowered
+}
+
+// trigrams returns the set of trigrams of s, padded like pg_trgm so short
+// strings and word boundaries count.
+func trigrams(s string) map[string]bool {
+	r := []rune("  " + s + " ")
+	set := make(map[string]bool, len(r))
+
+	for i := 0; i+3 <= len(r); i++ {
+		set[string(r[i:i+3])] = true
+	}
+
+	return set
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	shared := 0
+
+	for t := range a {
+		if b[t] {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// levenshtein returns the edit distance between a and b. With transpose,
+// swapping two adjacent characters counts as a single edit.
+func levenshtein(a, b string, transpose bool) int {
+	ra, rb := []rune(a), []rune(b)
+	// rows i-2, i-1 and i of the distance matrix
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+
+			if transpose && i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = minInt(cur[j], prev2[j-2]+1)
+			}
+		}
+
+		prev2, prev, cur = prev, cur, prev2
+	}
+
+	return prev[len(rb)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+
+	return m
+}
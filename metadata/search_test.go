@@ -0,0 +1,134 @@
+package metadata
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resultNames(results []SearchResult) []string {
+	names := make([]string, 0, len(results))
+	for i := range results {
+		names = append(names, results[i].Pkg.Name)
+	}
+
+	return names
+}
+
+func TestClientRankedSearch(t *testing.T) {
+	t.Parallel()
+
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	type testcase struct {
+		desc  string
+		query string
+		opts  *RankOptions
+		want  []string
+	}
+
+	tests := []testcase{
+		{
+			desc:  "exact name first, then by popularity",
+			query: "yay",
+			want:  []string{"yay", "yay-bin", "yay-git"},
+		},
+		{
+			desc:  "typo",
+			query: "yya",
+			opts:  &RankOptions{Limit: 1},
+			want:  []string{"yay"},
+		},
+		{
+			desc:  "typo in long name",
+			query: "liquidfz-git",
+			opts:  &RankOptions{Limit: 1},
+			want:  []string{"liquidsfz-git"},
+		},
+		{
+			desc:  "description words",
+			query: "SFZ sampler",
+			want:  []string{"liquidsfz-git"},
+		},
+		{
+			desc:  "keywords",
+			query: "amdgpu",
+			want:  []string{"linux-amd-git"},
+		},
+		{
+			desc:  "no match",
+			query: "qqqqqqqq",
+			want:  []string{},
+		},
+		{
+			desc:  "empty",
+			query: "  ",
+			want:  []string{},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			results, err := client.RankedSearch(ctx, test.query, test.opts)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, resultNames(results))
+		})
+	}
+}
+
+func TestClientRankedSearchWeights(t *testing.T) {
+	t.Parallel()
+
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	// without boosts equally similar names tie and sort by name
+	results, err := client.RankedSearch(ctx, "yay-xyz", &RankOptions{
+		Weights: &RankWeights{Name: 1},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, []string{"yay-bin", "yay-git", "yay"}, resultNames(results))
+	assert.Equal(t, results[0].Score, results[1].Score)
+
+	// votes lift the much more popular one
+	results, err = client.RankedSearch(ctx, "yay-xyz", &RankOptions{
+		Weights: &RankWeights{Name: 1, Votes: 1},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"yay", "yay-bin", "yay-git"}, resultNames(results))
+	assert.Greater(t, results[1].Score, results[2].Score)
+
+	results, err = client.RankedSearch(ctx, "yay", &RankOptions{MinRelevance: 0.99})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"yay"}, resultNames(results))
+}
+
+func TestLevenshtein(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 0, levenshtein("", "", false))
+	assert.Equal(t, 3, levenshtein("", "abc", false))
+	assert.Equal(t, 1, levenshtein("google-chrome", "google-chrone", false))
+	assert.Equal(t, 2, levenshtein("yay", "yya", false))
+	assert.Equal(t, 3, levenshtein("kitten", "sitting", false))
+	assert.Equal(t, 1, levenshtein("ä", "a", false))
+
+	// with transpositions
+	assert.Equal(t, 1, levenshtein("yay", "yya", true))
+	assert.Equal(t, 1, levenshtein("abcd", "acbd", true))
+	assert.Equal(t, 3, levenshtein("kitten", "sitting", true))
+	assert.Equal(t, 1, levenshtein("pärü", "päür", true))
+}
+
+func TestTokenize(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{"yet", "another", "yogurt", "pacman", "6", "0"},
+		tokenize("Yet another yogurt. (Pacman-6.0)"))
+	assert.Empty(t, tokenize(" -- "))
+}
@@ -0,0 +1,124 @@
+package metadata
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+// snapshotVersion must be bumped whenever the layout of dataset or aur.Pkg
+// changes, so snapshots written by older versions are ignored.
+const snapshotVersion = 1
+
+const snapshotMagic = "aur-metadata-snapshot"
+
+var errSnapshotStale = errors.New("snapshot is stale")
+
+// snapshotHeader identifies the cache file a snapshot was built from.
+type snapshotHeader struct {
+	Magic      string
+	Version    int
+	SourceSize int64
+	SourceTime int64
+	Fields     []string
+}
+
+func (a *Client) snapshotPath() string {
+	return a.cacheFilePath + ".snap"
+}
+
+// sourceHeader returns the header a snapshot of the cache file described by
+// info must have.
+func (a *Client) sourceHeader(info os.FileInfo) *snapshotHeader {
+	return &snapshotHeader{
+		Magic:      snapshotMagic,
+		Version:    snapshotVersion,
+		SourceSize: info.Size(),
+		SourceTime: info.ModTime().UnixNano(),
+		Fields:     a.fields,
+	}
+}
+
+// loadSnapshot reads the binary snapshot of the cache file described by info.
+// It fails with errSnapshotStale if the snapshot does not match the cache file.
+func (a *Client) loadSnapshot(info os.FileInfo) (*dataset, error) {
+	want := a.sourceHeader(info)
+
+	f, err := os.Open(a.snapshotPath())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dec := gob.NewDecoder(bufio.NewReader(f))
+
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return nil, fmt.Errorf("unable to decode snapshot header: %w", err)
+	}
+
+	if !reflect.DeepEqual(normalizeHeader(&header), normalizeHeader(want)) {
+		return nil, errSnapshotStale
+	}
+
+	ds := &dataset{}
+	if err := dec.Decode(ds); err != nil {
+		return nil, fmt.Errorf("unable to decode snapshot: %w", err)
+	}
+
+	if ds.ByName == nil {
+		ds.ByName = map[string]int{}
+	}
+
+	return ds, nil
+}
+
+// writeSnapshot stores ds as the binary snapshot of the cache file described
+// by info, which must be taken before ds was read from it: a snapshot tied to
+// a file replaced meanwhile would serve outdated packages.
+func (a *Client) writeSnapshot(ds *dataset, info os.FileInfo) error {
+	header := a.sourceHeader(info)
+	path := a.snapshotPath()
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	bw := bufio.NewWriter(f)
+	enc := gob.NewEncoder(bw)
+
+	if err := enc.Encode(header); err != nil {
+		return err
+	}
+
+	if err := enc.Encode(ds); err != nil {
+		return err
+	}
+
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+func normalizeHeader(h *snapshotHeader) snapshotHeader {
+	n := *h
+	if len(n.Fields) == 0 {
+		n.Fields = nil
+	}
+
+	return n
+}
@@ -0,0 +1,152 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientSnapshot(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	cacheFilePath := dir + "/cache.json"
+	ctx := context.Background()
+
+	testBytes, err := os.ReadFile("test.json")
+	require.NoError(t, err)
+
+	client, err := New(
+		WithCacheFilePath(cacheFilePath),
+		WithHTTPClient(&MockHTTP{bytesToReturn: testBytes}),
+		WithSnapshot(),
+	)
+	require.NoError(t, err)
+
+	want, err := client.cache(ctx)
+	require.NoError(t, err)
+	assert.FileExists(t, cacheFilePath+".snap")
+
+	logged := []string{}
+	client, err = New(
+		WithCacheFilePath(cacheFilePath),
+		WithHTTPClient(&MockHTTP{bytesToReturn: []byte("not json")}),
+		WithDebugLogger(func(s ...any) { logged = append(logged, fmt.Sprint(s...)) }),
+		WithSnapshot(),
+	)
+	require.NoError(t, err)
+
+	got, err := client.cache(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, want.Pkgs, got.Pkgs)
+	assert.Equal(t, want.ByName, got.ByName)
+	assert.Empty(t, logged)
+
+	// cache file changed, snapshot must be rebuilt
+	modTime := time.Now().Add(-time.Minute)
+	require.NoError(t, os.Chtimes(cacheFilePath, modTime, modTime))
+
+	info, err := os.Stat(cacheFilePath)
+	require.NoError(t, err)
+
+	_, err = client.loadSnapshot(info)
+	assert.ErrorIs(t, err, errSnapshotStale)
+
+	client.unmarshalledCache.Store(nil)
+	got, err = client.cache(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, want.Pkgs, got.Pkgs)
+	assert.True(t, modTime.Equal(got.modTime))
+	assert.Len(t, logged, 1)
+
+	_, err = client.loadSnapshot(info)
+	assert.NoError(t, err)
+
+	// different projection
+	client.fields = []string{"Version"}
+	_, err = client.loadSnapshot(info)
+	assert.ErrorIs(t, err, errSnapshotStale)
+}
+
+func TestClientSnapshotDisabled(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	cacheFilePath := dir + "/cache.json"
+
+	testBytes, err := os.ReadFile("test.json")
+	require.NoError(t, err)
+
+	client, err := New(
+		WithCacheFilePath(cacheFilePath),
+		WithHTTPClient(&MockHTTP{bytesToReturn: testBytes}),
+	)
+	require.NoError(t, err)
+
+	_, err = client.cache(context.Background())
+	require.NoError(t, err)
+	assert.NoFileExists(t, cacheFilePath+".snap")
+}
+
+func TestClientSnapshotCorrupt(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	cacheFilePath := dir + "/cache.json"
+
+	testBytes, err := os.ReadFile("test.json")
+	require.NoError(t, err)
+
+	client, err := New(
+		WithCacheFilePath(cacheFilePath),
+		WithHTTPClient(&MockHTTP{bytesToReturn: testBytes}),
+		WithSnapshot(),
+	)
+	require.NoError(t, err)
+
+	_, err = client.cache(context.Background())
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(cacheFilePath+".snap", []byte("garbage"), 0o600))
+
+	client.unmarshalledCache.Store(nil)
+	ds, err := client.cache(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, ds.Pkgs, 12)
+	assert.NotNil(t, ds.lookup("yay"))
+}
+
+func TestClientSnapshotSourceReplaced(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	cacheFilePath := dir + "/cache.json"
+
+	testBytes, err := os.ReadFile("test.json")
+	require.NoError(t, err)
+
+	client, err := New(
+		WithCacheFilePath(cacheFilePath),
+		WithHTTPClient(&MockHTTP{bytesToReturn: testBytes}),
+		WithSnapshot(),
+	)
+	require.NoError(t, err)
+
+	ds, err := client.cache(context.Background())
+	require.NoError(t, err)
+
+	parsed, err := os.Stat(cacheFilePath)
+	require.NoError(t, err)
+
+	// the cache file is replaced while the old one is parsed
+	modTime := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(cacheFilePath, modTime, modTime))
+	client.saveSnapshot(ds, parsed)
+
+	current, err := os.Stat(cacheFilePath)
+	require.NoError(t, err)
+
+	_, err = client.loadSnapshot(current)
+	assert.ErrorIs(t, err, errSnapshotStale)
+}
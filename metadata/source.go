@@ -0,0 +1,131 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrNoSource is returned when refreshing a client created with
+// NewFromReader, which has nothing to refresh from, or when asking it for
+// name lists without a cache file path.
+var ErrNoSource = errors.New("aur metadata client has no source to refresh from")
+
+// SourceFn opens a metadata dump: a JSON array of packages as published by
+// aurweb, optionally gzip compressed.
+type SourceFn func(ctx context.Context) (io.ReadCloser, error)
+
+// WithSource obtains the metadata from fn instead of downloading it from
+// aurweb, e.g. to read it from an artifact store. The data is cached and
+// refreshed the same way as a download.
+func WithSource(fn SourceFn) ClientOption {
+	return func(c *Client) error {
+		c.source = fn
+
+		return nil
+	}
+}
+
+// WithFile obtains the metadata from a local dump, see FileSource.
+func WithFile(path string) ClientOption {
+	return WithSource(FileSource(path))
+}
+
+// FileSource reads the metadata from a local .json or .json.gz file.
+// If path is a directory the most recently modified dump in it is used,
+// which suits directories receiving a new dump periodically.
+func FileSource(path string) SourceFn {
+	return func(ctx context.Context) (io.ReadCloser, error) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read metadata dump: %w", err)
+		}
+
+		dumpPath := path
+		if info.IsDir() {
+			if dumpPath, err = latestDump(path); err != nil {
+				return nil, err
+			}
+		}
+
+		f, err := os.Open(dumpPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read metadata dump: %w", err)
+		}
+
+		return f, nil
+	}
+}
+
+// latestDump returns the most recently modified dump in dir.
+func latestDump(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("unable to read metadata dump: %w", err)
+	}
+
+	var (
+		latest     string
+		latestTime time.Time
+	)
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !(strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".json.gz")) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return "", fmt.Errorf("unable to read metadata dump: %w", err)
+		}
+
+		if latest == "" || info.ModTime().After(latestTime) {
+			latest, latestTime = name, info.ModTime()
+		}
+	}
+
+	if latest == "" {
+		return "", fmt.Errorf("no metadata dump found in %s", dir)
+	}
+
+	return filepath.Join(dir, latest), nil
+}
+
+// NewFromReader creates a client serving the metadata dump read from r,
+// which may be gzip compressed. The data is kept in memory only and never
+// refreshed: Refresh and StartAutoRefresh return ErrNoSource. The name list
+// archives are only available with a cache file path to keep them at.
+func NewFromReader(r io.Reader, opts ...ClientOption) (*Client, error) {
+	client, err := newClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if client.cacheFilePath != "" {
+		client.setCachePaths()
+	}
+
+	body, err := maybeGunzip(io.NopCloser(r))
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	pkgs, err := DecodePkgs(body, client.fields...)
+	if err != nil {
+		return nil, fmt.Errorf("aur metadata unable to parse dump: %w", err)
+	}
+
+	ds := newDataset(pkgs)
+	ds.modTime = time.Now()
+	client.static = true
+	client.unmarshalledCache.Store(ds)
+
+	return client, nil
+}
@@ -0,0 +1,131 @@
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Jguer/aur"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFromReader(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	testBytes, err := os.ReadFile("test.json")
+	require.NoError(t, err)
+
+	for name, dump := range map[string][]byte{
+		"plain": testBytes,
+		"gzip":  gzipBytes(t, string(testBytes)),
+	} {
+		dump := dump
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			client, err := NewFromReader(bytes.NewReader(dump), WithCacheFilePath(t.TempDir()+"/cache.json"))
+			require.NoError(t, err)
+
+			pkgs, err := client.Get(ctx, &aur.Query{Needles: []string{"yay"}, By: aur.Name})
+			require.NoError(t, err)
+			require.Len(t, pkgs, 1)
+			assert.Equal(t, "11.3.0-1", pkgs[0].Version)
+
+			info, ok := client.DataInfo()
+			require.True(t, ok)
+			assert.False(t, info.Stale)
+
+			assert.ErrorIs(t, client.Refresh(ctx), ErrNoSource)
+			assert.ErrorIs(t, client.StartAutoRefresh(ctx), ErrNoSource)
+			assert.NoFileExists(t, client.cacheFilePath)
+		})
+	}
+
+	// no cache directory is created for a client without cache file path
+	client, err := NewFromReader(bytes.NewReader(testBytes))
+	require.NoError(t, err)
+	assert.Empty(t, client.cacheFilePath)
+
+	pkgs, err := client.Get(ctx, &aur.Query{Needles: []string{"yay"}, By: aur.Name})
+	require.NoError(t, err)
+	assert.Len(t, pkgs, 1)
+
+	_, err = client.PackageNames(ctx)
+	assert.ErrorIs(t, err, ErrNoSource)
+
+	_, err = NewFromReader(bytes.NewReader([]byte("<html>")))
+	assert.Error(t, err)
+}
+
+func TestClientWithFile(t *testing.T) {
+	t.Parallel()
+
+	testBytes, err := os.ReadFile("test.json")
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	dumpPath := filepath.Join(dir, "packages-meta-ext-v1.json.gz")
+	require.NoError(t, os.WriteFile(dumpPath, gzipBytes(t, string(testBytes)), 0o600))
+
+	client, err := New(WithCacheFilePath(t.TempDir()+"/cache.json"), WithFile(dumpPath))
+	require.NoError(t, err)
+
+	assert.Equal(t, "11.3.0-1", yayVersion(t, client))
+	assert.FileExists(t, client.cacheFilePath)
+}
+
+func TestClientWithFileDirectory(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a dump"), 0o600))
+
+	client, err := New(WithCacheFilePath(t.TempDir()+"/cache.json"), WithFile(dir))
+	require.NoError(t, err)
+
+	assert.ErrorContains(t, client.Refresh(ctx), "no metadata dump found")
+
+	old := time.Now().Add(-time.Hour)
+	oldPath := filepath.Join(dir, "old.json")
+	require.NoError(t, os.WriteFile(oldPath, []byte(`[{"Name":"yay","Version":"1.0.0-1"}]`), 0o600))
+	require.NoError(t, os.Chtimes(oldPath, old, old))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new.json.gz"),
+		gzipBytes(t, `[{"Name":"yay","Version":"2.0.0-1"}]`), 0o600))
+
+	require.NoError(t, client.Refresh(ctx))
+	assert.Equal(t, "2.0.0-1", yayVersion(t, client))
+}
+
+func TestClientWithSource(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	errStore := errors.New("artifact store unavailable")
+	var fail bool
+
+	client, err := New(
+		WithCacheFilePath(t.TempDir()+"/cache.json"),
+		WithSource(func(ctx context.Context) (io.ReadCloser, error) {
+			if fail {
+				return nil, errStore
+			}
+
+			return io.NopCloser(bytes.NewReader([]byte(`[{"Name":"yay","Version":"1.0.0-1"}]`))), nil
+		}),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, "1.0.0-1", yayVersion(t, client))
+
+	fail = true
+	assert.ErrorIs(t, client.Refresh(ctx), errStore)
+}
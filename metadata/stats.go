@@ -0,0 +1,223 @@
+package metadata
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Jguer/aur"
+)
+
+// Default histogram bucket edges.
+var (
+	DefaultVoteEdges       = []float64{0, 1, 10, 100, 1000}
+	DefaultPopularityEdges = []float64{0, 0.01, 0.1, 1, 10}
+)
+
+// StatsOptions tunes Stats.
+type StatsOptions struct {
+	// Top limits the frequency lists to their largest entries, 0 keeps all.
+	Top int
+	// VoteEdges and PopularityEdges are the ascending lower bounds of the
+	// histogram buckets. They default to DefaultVoteEdges and
+	// DefaultPopularityEdges.
+	VoteEdges       []float64
+	PopularityEdges []float64
+}
+
+// Stats are aggregate statistics over the AUR metadata.
+type Stats struct {
+	Packages       int     `json:"Packages"`
+	PackageBases   int     `json:"PackageBases"`
+	Maintainers    int     `json:"Maintainers"`
+	Orphaned       int     `json:"Orphaned"`
+	OutOfDate      int     `json:"OutOfDate"`
+	OutOfDateRatio float64 `json:"OutOfDateRatio"`
+
+	// PackagesPerMaintainer, Licenses and Keywords are sorted by decreasing
+	// count. Keywords are lowercased.
+	PackagesPerMaintainer []Count `json:"PackagesPerMaintainer"`
+	Licenses              []Count `json:"Licenses"`
+	Keywords              []Count `json:"Keywords"`
+
+	Votes      []Bucket `json:"Votes"`
+	Popularity []Bucket `json:"Popularity"`
+
+	// Submitted and Modified count the packages first submitted and last
+	// modified per month, in ascending order.
+	Submitted []MonthCount `json:"Submitted"`
+	Modified  []MonthCount `json:"Modified"`
+}
+
+// Count is the number of packages having a value.
+type Count struct {
+	Name  string `json:"Name"`
+	Count int    `json:"Count"`
+}
+
+// Bucket is a histogram bucket counting values in [Min, Max).
+// Max is zero for the last, unbounded bucket.
+type Bucket struct {
+	Min   float64 `json:"Min"`
+	Max   float64 `json:"Max,omitempty"`
+	Count int     `json:"Count"`
+}
+
+// MonthCount is the number of packages in a month, formatted as 2006-01.
+type MonthCount struct {
+	Month string `json:"Month"`
+	Count int    `json:"Count"`
+}
+
+// Stats computes aggregate statistics over all packages.
+func (a *Client) Stats(ctx context.Context, opts StatsOptions) (*Stats, error) {
+	ds, err := a.cache(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.VoteEdges == nil {
+		opts.VoteEdges = DefaultVoteEdges
+	}
+
+	if opts.PopularityEdges == nil {
+		opts.PopularityEdges = DefaultPopularityEdges
+	}
+
+	return computeStats(ds.Pkgs, &opts), nil
+}
+
+func computeStats(pkgs []aur.Pkg, opts *StatsOptions) *Stats {
+	stats := &Stats{
+		Packages:   len(pkgs),
+		Votes:      newHistogram(opts.VoteEdges),
+		Popularity: newHistogram(opts.PopularityEdges),
+	}
+
+	bases := make(map[string]bool)
+	maintainers := make(map[string]int)
+	licenses := make(map[string]int)
+	keywords := make(map[string]int)
+	submitted := make(map[string]int)
+	modified := make(map[string]int)
+
+	for i := range pkgs {
+		pkg := &pkgs[i]
+
+		bases[pkg.PackageBase] = true
+
+		if pkg.Maintainer == "" {
+			stats.Orphaned++
+		} else {
+			maintainers[pkg.Maintainer]++
+		}
+
+		if pkg.OutOfDate != 0 {
+			stats.OutOfDate++
+		}
+
+		for _, license := range pkg.License {
+			licenses[license]++
+		}
+
+		// a package listing a keyword twice counts once
+		seen := make(map[string]bool, len(pkg.Keywords))
+
+		for _, keyword := range pkg.Keywords {
+			keyword = strings.ToLower(keyword)
+			if !seen[keyword] {
+				seen[keyword] = true
+				keywords[keyword]++
+			}
+		}
+
+		addToHistogram(stats.Votes, float64(pkg.NumVotes))
+		addToHistogram(stats.Popularity, pkg.Popularity)
+
+		if pkg.FirstSubmitted != 0 {
+			submitted[month(pkg.FirstSubmitted)]++
+		}
+
+		if pkg.LastModified != 0 {
+			modified[month(pkg.LastModified)]++
+		}
+	}
+
+	stats.PackageBases = len(bases)
+	stats.Maintainers = len(maintainers)
+
+	if len(pkgs) != 0 {
+		stats.OutOfDateRatio = float64(stats.OutOfDate) / float64(len(pkgs))
+	}
+
+	stats.PackagesPerMaintainer = topCounts(maintainers, opts.Top)
+	stats.Licenses = topCounts(licenses, opts.Top)
+	stats.Keywords = topCounts(keywords, opts.Top)
+	stats.Submitted = monthCounts(submitted)
+	stats.Modified = monthCounts(modified)
+
+	return stats
+}
+
+func newHistogram(edges []float64) []Bucket {
+	buckets := make([]Bucket, len(edges))
+	for i, edge := range edges {
+		buckets[i].Min = edge
+		if i+1 < len(edges) {
+			buckets[i].Max = edges[i+1]
+		}
+	}
+
+	return buckets
+}
+
+// addToHistogram counts v in the last bucket starting at or below it.
+// Values below the first edge are not counted.
+func addToHistogram(buckets []Bucket, v float64) {
+	for i := len(buckets) - 1; i >= 0; i-- {
+		if v >= buckets[i].Min {
+			buckets[i].Count++
+
+			return
+		}
+	}
+}
+
+func month(timestamp int) string {
+	return time.Unix(int64(timestamp), 0).UTC().Format("2006-01")
+}
+
+// topCounts returns the top entries of counts by decreasing count,
+// ties ordered by name.
+func topCounts(counts map[string]int, top int) []Count {
+	out := make([]Count, 0, len(counts))
+	for name, count := range counts {
+		out = append(out, Count{Name: name, Count: count})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+
+		return out[i].Name < out[j].Name
+	})
+
+	if top > 0 && len(out) > top {
+		out = out[:top]
+	}
+
+	return out
+}
+
+func monthCounts(counts map[string]int) []MonthCount {
+	out := make([]MonthCount, 0, len(counts))
+	for m, count := range counts {
+		out = append(out, MonthCount{Month: m, Count: count})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Month < out[j].Month })
+
+	return out
+}
@@ -0,0 +1,91 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Jguer/aur"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientStats(t *testing.T) {
+	t.Parallel()
+
+	aug := int(time.Date(2022, time.August, 14, 0, 0, 0, 0, time.UTC).Unix())
+	nov := int(time.Date(2022, time.November, 5, 0, 0, 0, 0, time.UTC).Unix())
+
+	client := newDatasetClient(t, []aur.Pkg{
+		{
+			Name: "yay", PackageBase: "yay", Maintainer: "jguer", NumVotes: 1855, Popularity: 39.7,
+			License: []string{"GPL3"}, Keywords: []string{"AUR", "helper", "aur"},
+			FirstSubmitted: aug, LastModified: nov,
+		},
+		{
+			Name: "yay-bin", PackageBase: "yay-bin", Maintainer: "jguer", NumVotes: 218, Popularity: 2.1,
+			License: []string{"GPL3"}, Keywords: []string{"aur"}, FirstSubmitted: aug, LastModified: aug,
+		},
+		{
+			Name: "jack-audio-tools-common", PackageBase: "jack-audio-tools", Maintainer: "Terence",
+			License: []string{"MIT"}, OutOfDate: nov, FirstSubmitted: nov, LastModified: nov,
+		},
+		{Name: "jack-audio-tools-lv2", PackageBase: "jack-audio-tools", NumVotes: 5, Popularity: 0.05},
+	})
+
+	stats, err := client.Stats(context.Background(), StatsOptions{Top: 1})
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, stats.Packages)
+	assert.Equal(t, 3, stats.PackageBases)
+	assert.Equal(t, 2, stats.Maintainers)
+	assert.Equal(t, 1, stats.Orphaned)
+	assert.Equal(t, 1, stats.OutOfDate)
+	assert.InDelta(t, 0.25, stats.OutOfDateRatio, 1e-9)
+
+	assert.Equal(t, []Count{{Name: "jguer", Count: 2}}, stats.PackagesPerMaintainer)
+	assert.Equal(t, []Count{{Name: "GPL3", Count: 2}}, stats.Licenses)
+	assert.Equal(t, []Count{{Name: "aur", Count: 2}}, stats.Keywords)
+
+	assert.Equal(t, []Bucket{
+		{Min: 0, Max: 1, Count: 1},
+		{Min: 1, Max: 10, Count: 1},
+		{Min: 10, Max: 100, Count: 0},
+		{Min: 100, Max: 1000, Count: 1},
+		{Min: 1000, Count: 1},
+	}, stats.Votes)
+	assert.Equal(t, []int{1, 1, 0, 1, 1}, bucketCounts(stats.Popularity))
+
+	assert.Equal(t, []MonthCount{{Month: "2022-08", Count: 2}, {Month: "2022-11", Count: 1}}, stats.Submitted)
+	assert.Equal(t, []MonthCount{{Month: "2022-08", Count: 1}, {Month: "2022-11", Count: 2}}, stats.Modified)
+
+	b, err := json.Marshal(stats)
+	require.NoError(t, err)
+
+	var decoded Stats
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, *stats, decoded)
+}
+
+func TestClientStatsCustomEdges(t *testing.T) {
+	t.Parallel()
+
+	client := newTestClient(t)
+
+	stats, err := client.Stats(context.Background(), StatsOptions{VoteEdges: []float64{0, 100}})
+	require.NoError(t, err)
+
+	assert.Equal(t, 12, stats.Packages)
+	assert.Equal(t, []int{10, 2}, bucketCounts(stats.Votes))
+	assert.Len(t, stats.Popularity, len(DefaultPopularityEdges))
+}
+
+func bucketCounts(buckets []Bucket) []int {
+	counts := make([]int, 0, len(buckets))
+	for _, b := range buckets {
+		counts = append(counts, b.Count)
+	}
+
+	return counts
+}
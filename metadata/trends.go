@@ -0,0 +1,419 @@
+package metadata
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jguer/aur"
+)
+
+const (
+	samplePrefix = "samples-"
+	sampleSuffix = ".tsv.gz"
+)
+
+// ErrNoSamples is returned by trend queries before any sample was recorded.
+var ErrNoSamples = errors.New("no trend samples recorded")
+
+// Trends is a local time series of the votes and popularity of every
+// package, sampled each time the metadata is recorded.
+// Trends is safe for concurrent use within a process.
+type Trends struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// Sample is the state of a package at a point in time.
+type Sample struct {
+	Time         time.Time `json:"Time"`
+	NumVotes     int       `json:"NumVotes"`
+	Popularity   float64   `json:"Popularity"`
+	LastModified int       `json:"LastModified"`
+}
+
+// Growth is how a package changed between two samples.
+type Growth struct {
+	Name string `json:"Name"`
+	From Sample `json:"From"`
+	To   Sample `json:"To"`
+	// Votes and Popularity are the changes from From to To.
+	Votes      int     `json:"Votes"`
+	Popularity float64 `json:"Popularity"`
+	// VotesPerDay is zero when both samples are the same.
+	VotesPerDay float64 `json:"VotesPerDay"`
+}
+
+// Metric is a value tracked by Trends.
+type Metric int
+
+const (
+	MetricVotes Metric = iota
+	MetricPopularity
+)
+
+// MoversOptions tunes TopMovers.
+type MoversOptions struct {
+	// Since is the start of the period, see Trends.Growth.
+	Since time.Time
+	By    Metric
+	// Falling ranks the largest decreases instead of increases.
+	Falling bool
+	// Top limits the result to the first packages, 0 keeps all.
+	Top int
+}
+
+// AbandonedOptions tunes Abandoned.
+type AbandonedOptions struct {
+	// Since is the start of the period, see Trends.Growth.
+	Since time.Time
+	// MaxPopularity is the highest popularity left to an abandoned package.
+	MaxPopularity float64
+}
+
+// OpenTrends opens the trend store in dir, creating it if needed.
+func OpenTrends(dir string) (*Trends, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("unable to create trends: %w", err)
+	}
+
+	return &Trends{dir: dir}, nil
+}
+
+// WithTrends samples every new version of the metadata into tr.
+func WithTrends(tr *Trends) ClientOption {
+	return func(c *Client) error {
+		c.recorders = append(c.recorders, tr)
+
+		return nil
+	}
+}
+
+// Record stores the votes and popularity of pkgs as sampled at t.
+// Samples not newer than the latest recorded one are ignored.
+func (tr *Trends) Record(t time.Time, pkgs []aur.Pkg) error {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	files, err := tr.files()
+	if err != nil {
+		return err
+	}
+
+	if len(files) != 0 && !t.After(files[len(files)-1].time) {
+		return nil
+	}
+
+	f, err := os.CreateTemp(tr.dir, samplePrefix+"*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	zw := gzip.NewWriter(f)
+	w := bufio.NewWriter(zw)
+
+	for i := range pkgs {
+		pkg := &pkgs[i]
+
+		fmt.Fprintf(w, "%s\t%d\t%s\t%d\n", pkg.Name, pkg.NumVotes,
+			strconv.FormatFloat(pkg.Popularity, 'g', -1, 64), pkg.LastModified)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filepath.Join(tr.dir, recordName(samplePrefix, t, sampleSuffix)))
+}
+
+// Prune removes the samples taken before t.
+func (tr *Trends) Prune(before time.Time) error {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	files, err := tr.files()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if !file.time.Before(before) {
+			break
+		}
+
+		if err := os.Remove(file.path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Series returns the samples of the package called name, oldest first.
+func (tr *Trends) Series(name string) ([]Sample, error) {
+	files, err := tr.files()
+	if err != nil {
+		return nil, err
+	}
+
+	series := make([]Sample, 0, len(files))
+
+	for _, file := range files {
+		samples, errR := readSamples(file, name)
+		if errR != nil {
+			return nil, errR
+		}
+
+		if sample, ok := samples[name]; ok {
+			series = append(series, sample)
+		}
+	}
+
+	return series, nil
+}
+
+// Growth returns how the packages present in both samples changed from the
+// last sample taken at or before since, or the oldest one, to the latest
+// sample. Packages are sorted by name.
+func (tr *Trends) Growth(since time.Time) ([]Growth, error) {
+	from, to, err := tr.window(since)
+	if err != nil {
+		return nil, err
+	}
+
+	fromSamples, err := readSamples(from, "")
+	if err != nil {
+		return nil, err
+	}
+
+	toSamples := fromSamples
+	if to != from {
+		if toSamples, err = readSamples(to, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	growth := make([]Growth, 0, len(toSamples))
+
+	for name, last := range toSamples {
+		first, ok := fromSamples[name]
+		if ok {
+			growth = append(growth, newGrowth(name, first, last))
+		}
+	}
+
+	sort.Slice(growth, func(i, j int) bool { return growth[i].Name < growth[j].Name })
+
+	return growth, nil
+}
+
+// PackageGrowth returns how the package called name changed, as Growth
+// does. It returns nil if the package is missing from either sample.
+func (tr *Trends) PackageGrowth(name string, since time.Time) (*Growth, error) {
+	from, to, err := tr.window(since)
+	if err != nil {
+		return nil, err
+	}
+
+	fromSamples, err := readSamples(from, name)
+	if err != nil {
+		return nil, err
+	}
+
+	toSamples, err := readSamples(to, name)
+	if err != nil {
+		return nil, err
+	}
+
+	first, okFrom := fromSamples[name]
+	last, okTo := toSamples[name]
+
+	if !okFrom || !okTo {
+		return nil, nil
+	}
+
+	growth := newGrowth(name, first, last)
+
+	return &growth, nil
+}
+
+// TopMovers returns the packages whose metric changed most in the period,
+// leaving out those that did not move in the requested direction.
+func (tr *Trends) TopMovers(opts MoversOptions) ([]Growth, error) {
+	growth, err := tr.Growth(opts.Since)
+	if err != nil {
+		return nil, err
+	}
+
+	change := func(g *Growth) float64 {
+		if opts.By == MetricPopularity {
+			return g.Popularity
+		}
+
+		return float64(g.Votes)
+	}
+
+	if opts.Falling {
+		inner := change
+		change = func(g *Growth) float64 { return -inner(g) }
+	}
+
+	movers := growth[:0]
+
+	for i := range growth {
+		if change(&growth[i]) > 0 {
+			movers = append(movers, growth[i])
+		}
+	}
+
+	// stable, so ties stay ordered by name
+	sort.SliceStable(movers, func(i, j int) bool { return change(&movers[i]) > change(&movers[j]) })
+
+	if opts.Top > 0 && len(movers) > opts.Top {
+		movers = movers[:opts.Top]
+	}
+
+	return movers, nil
+}
+
+// Abandoned returns the packages which during the period were not updated,
+// gained no votes and whose popularity did not grow, ending at most at
+// opts.MaxPopularity. It needs samples spanning the period.
+func (tr *Trends) Abandoned(opts AbandonedOptions) ([]Growth, error) {
+	growth, err := tr.Growth(opts.Since)
+	if err != nil {
+		return nil, err
+	}
+
+	abandoned := growth[:0]
+
+	// a single sample tells nothing about the period
+	if len(growth) != 0 && !growth[0].From.Time.Before(growth[0].To.Time) {
+		return abandoned, nil
+	}
+
+	for i := range growth {
+		g := &growth[i]
+		if g.Votes <= 0 && g.Popularity <= 0 && g.To.Popularity <= opts.MaxPopularity &&
+			g.From.LastModified == g.To.LastModified {
+			abandoned = append(abandoned, *g)
+		}
+	}
+
+	return abandoned, nil
+}
+
+func newGrowth(name string, from, to Sample) Growth {
+	growth := Growth{
+		Name:       name,
+		From:       from,
+		To:         to,
+		Votes:      to.NumVotes - from.NumVotes,
+		Popularity: to.Popularity - from.Popularity,
+	}
+
+	if days := to.Time.Sub(from.Time).Hours() / 24; days > 0 {
+		growth.VotesPerDay = float64(growth.Votes) / days
+	}
+
+	return growth
+}
+
+// window returns the samples bounding the period starting at since.
+func (tr *Trends) window(since time.Time) (from, to recordFile, err error) {
+	files, err := tr.files()
+	if err != nil {
+		return from, to, err
+	}
+
+	if len(files) == 0 {
+		return from, to, ErrNoSamples
+	}
+
+	from = files[0]
+
+	for _, file := range files {
+		if file.time.After(since) {
+			break
+		}
+
+		from = file
+	}
+
+	return from, files[len(files)-1], nil
+}
+
+// files returns the samples, oldest first.
+func (tr *Trends) files() ([]recordFile, error) {
+	files, err := listRecords(tr.dir, samplePrefix, sampleSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read trends: %w", err)
+	}
+
+	return files, nil
+}
+
+// readSamples reads the sample file, only the line of name if set.
+func readSamples(file recordFile, name string) (map[string]Sample, error) {
+	f, err := os.Open(file.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read samples %s: %w", file.path, err)
+	}
+	defer zr.Close()
+
+	samples := make(map[string]Sample)
+	scanner := bufio.NewScanner(zr)
+	prefix := name + "\t"
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name != "" && !strings.HasPrefix(line, prefix) {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unable to read samples %s: malformed line %q", file.path, line)
+		}
+
+		votes, errV := strconv.Atoi(fields[1])
+		popularity, errP := strconv.ParseFloat(fields[2], 64)
+		modified, errM := strconv.Atoi(fields[3])
+
+		if errV != nil || errP != nil || errM != nil {
+			return nil, fmt.Errorf("unable to read samples %s: malformed line %q", file.path, line)
+		}
+
+		samples[fields[0]] = Sample{Time: file.time, NumVotes: votes, Popularity: popularity, LastModified: modified}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read samples %s: %w", file.path, err)
+	}
+
+	return samples, nil
+}
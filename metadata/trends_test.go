@@ -0,0 +1,159 @@
+package metadata
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Jguer/aur"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func growthNames(growth []Growth) []string {
+	names := []string{}
+	for i := range growth {
+		names = append(names, growth[i].Name)
+	}
+
+	return names
+}
+
+func newTestTrends(t *testing.T) *Trends {
+	t.Helper()
+
+	tr, err := OpenTrends(t.TempDir())
+	require.NoError(t, err)
+
+	day := func(d int) time.Time { return time.Date(2022, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	require.NoError(t, tr.Record(day(1), []aur.Pkg{
+		{Name: "yay", NumVotes: 100, Popularity: 10, LastModified: 1},
+		{Name: "paru", NumVotes: 50, Popularity: 5, LastModified: 1},
+		{Name: "dead", NumVotes: 3, Popularity: 0.02, LastModified: 1},
+		{Name: "fading", NumVotes: 30, Popularity: 2, LastModified: 1},
+		{Name: "removed", NumVotes: 1, LastModified: 1},
+	}))
+	require.NoError(t, tr.Record(day(11), []aur.Pkg{
+		{Name: "yay", NumVotes: 110, Popularity: 11, LastModified: 2},
+		{Name: "paru", NumVotes: 70, Popularity: 7, LastModified: 1},
+		{Name: "dead", NumVotes: 3, Popularity: 0.01, LastModified: 1},
+		{Name: "fading", NumVotes: 30, Popularity: 1, LastModified: 1},
+		{Name: "new", NumVotes: 1, LastModified: 2},
+	}))
+	// not newer than the last sample
+	require.NoError(t, tr.Record(day(11), []aur.Pkg{}))
+
+	return tr
+}
+
+func TestTrendsGrowth(t *testing.T) {
+	t.Parallel()
+
+	tr := newTestTrends(t)
+
+	growth, err := tr.Growth(time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dead", "fading", "paru", "yay"}, growthNames(growth))
+
+	g, err := tr.PackageGrowth("paru", time.Date(2022, 1, 5, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.NotNil(t, g)
+	assert.Equal(t, 20, g.Votes)
+	assert.InDelta(t, 2, g.Popularity, 1e-9)
+	assert.InDelta(t, 2, g.VotesPerDay, 1e-9)
+
+	// the period starts at the latest sample
+	g, err = tr.PackageGrowth("paru", time.Now())
+	require.NoError(t, err)
+	require.NotNil(t, g)
+	assert.Zero(t, g.Votes)
+	assert.Zero(t, g.VotesPerDay)
+
+	g, err = tr.PackageGrowth("new", time.Time{})
+	require.NoError(t, err)
+	assert.Nil(t, g)
+
+	series, err := tr.Series("yay")
+	require.NoError(t, err)
+	require.Len(t, series, 2)
+	assert.Equal(t, 100, series[0].NumVotes)
+	assert.Equal(t, 110, series[1].NumVotes)
+
+	series, err = tr.Series("y")
+	require.NoError(t, err)
+	assert.Empty(t, series)
+}
+
+func TestTrendsTopMovers(t *testing.T) {
+	t.Parallel()
+
+	tr := newTestTrends(t)
+
+	movers, err := tr.TopMovers(MoversOptions{By: MetricVotes})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"paru", "yay"}, growthNames(movers))
+
+	movers, err = tr.TopMovers(MoversOptions{By: MetricPopularity, Top: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"paru"}, growthNames(movers))
+
+	movers, err = tr.TopMovers(MoversOptions{By: MetricPopularity, Falling: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fading", "dead"}, growthNames(movers))
+}
+
+func TestTrendsAbandoned(t *testing.T) {
+	t.Parallel()
+
+	tr := newTestTrends(t)
+
+	abandoned, err := tr.Abandoned(AbandonedOptions{MaxPopularity: 0.1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dead"}, growthNames(abandoned))
+
+	abandoned, err = tr.Abandoned(AbandonedOptions{MaxPopularity: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dead", "fading"}, growthNames(abandoned))
+
+	abandoned, err = tr.Abandoned(AbandonedOptions{Since: time.Now(), MaxPopularity: 1})
+	require.NoError(t, err)
+	assert.Empty(t, abandoned)
+}
+
+func TestTrendsPrune(t *testing.T) {
+	t.Parallel()
+
+	tr := newTestTrends(t)
+
+	require.NoError(t, tr.Prune(time.Date(2022, 1, 5, 0, 0, 0, 0, time.UTC)))
+
+	series, err := tr.Series("yay")
+	require.NoError(t, err)
+	require.Len(t, series, 1)
+	assert.Equal(t, 110, series[0].NumVotes)
+
+	empty, err := OpenTrends(t.TempDir())
+	require.NoError(t, err)
+
+	_, err = empty.Growth(time.Time{})
+	assert.ErrorIs(t, err, ErrNoSamples)
+}
+
+func TestClientWithTrends(t *testing.T) {
+	t.Parallel()
+
+	tr, err := OpenTrends(t.TempDir())
+	require.NoError(t, err)
+
+	client := newTestClient(t, WithTrends(tr))
+
+	info, err := client.Load(context.Background())
+	require.NoError(t, err)
+	assert.False(t, info.Stale)
+
+	series, err := tr.Series("yay")
+	require.NoError(t, err)
+	require.Len(t, series, 1)
+	assert.True(t, series[0].Time.Equal(info.UpdatedAt))
+}
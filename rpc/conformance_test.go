@@ -0,0 +1,22 @@
+package rpc
+
+import (
+	"testing"
+
+	"github.com/Jguer/aur"
+	"github.com/Jguer/aur/internal/conformance"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientConformance(t *testing.T) {
+	t.Parallel()
+
+	conformance.Run(t, func(t *testing.T, pkgs []aur.Pkg) conformance.Client {
+		srv := conformance.NewServer(t, pkgs)
+
+		client, err := NewClient(WithBaseURL(srv.URL))
+		require.NoError(t, err)
+
+		return client
+	})
+}
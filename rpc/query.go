@@ -114,6 +114,8 @@ func (c *Client) get(ctx context.Context, values url.Values) ([]aur.Pkg, error)
 	return parseRPCResponse(resp)
 }
 
+// Get queries the AUR for the needles of query. Sorting and pagination are
+// not supported by the RPC and are emulated on the results.
 func (c *Client) Get(ctx context.Context, query *aur.Query) ([]aur.Pkg, error) {
 	if len(query.Needles) == 0 {
 		return []aur.Pkg{}, nil
@@ -125,17 +127,31 @@ func (c *Client) Get(ctx context.Context, query *aur.Query) ([]aur.Pkg, error) {
 			return nil, err
 		}
 
+		// search results carry every sortable field,
+		// so only the details of the requested page are fetched
+		pkgs = query.Paginate(pkgs)
+
 		if c.batchSize != 0 && len(pkgs) < c.batchSize*4 {
 			names := make([]string, 0, len(pkgs))
 			for i := range pkgs {
 				names = append(names, pkgs[i].Name)
 			}
 
-			return c.batchInfo(ctx, names)
+			info, err := c.batchInfo(ctx, names)
+			if err == nil && query.SortBy != 0 {
+				aur.SortPkgs(info, query.SortBy, query.Descending)
+			}
+
+			return info, err
 		}
 
 		return pkgs, nil
 	}
 
-	return c.batchInfo(ctx, query.Needles)
+	info, err := c.batchInfo(ctx, query.Needles)
+	if err != nil {
+		return info, err
+	}
+
+	return query.Paginate(info), nil
 }
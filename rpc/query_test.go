@@ -369,3 +369,72 @@ func TestClient_NoBatch(t *testing.T) {
 	assert.Equal(t, "https://aur.archlinux.org/rpc?arg=test&by=name&type=search&v=5",
 		requestMade.URL.String())
 }
+
+const multiSearchPayload = `{"version":5,"type":"search","resultcount":3,"results":[
+{"Name":"a","NumVotes":5,"Popularity":0.1},
+{"Name":"b","NumVotes":50,"Popularity":2.5},
+{"Name":"c","NumVotes":10,"Popularity":0.3}]}`
+
+const multiInfoPayload = `{"version":5,"type":"multiinfo","resultcount":2,"results":[
+{"Name":"c","NumVotes":10,"Popularity":0.3,"Version":"1"},
+{"Name":"b","NumVotes":50,"Popularity":2.5,"Version":"1"}]}`
+
+func TestClient_GetSortedPage(t *testing.T) {
+	testClient := new(MockedClient)
+
+	c, err := NewClient(WithHTTPClient(testClient), WithBatchSize(10))
+	require.NoError(t, err)
+
+	testClient.On("Do", mock.Anything).Return(&http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(bytes.NewBufferString(multiSearchPayload)),
+	}, nil).Once()
+
+	testClient.On("Do", mock.Anything).Return(&http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(bytes.NewBufferString(multiInfoPayload)),
+	}, nil).Once()
+
+	got, err := c.Get(context.Background(), &aur.Query{
+		By:         aur.Name,
+		Contains:   true,
+		Needles:    []string{"test"},
+		SortBy:     aur.SortVotes,
+		Descending: true,
+		Limit:      2,
+	})
+	require.NoError(t, err)
+
+	names := []string{}
+	for i := range got {
+		names = append(names, got[i].Name)
+	}
+
+	assert.Equal(t, []string{"b", "c"}, names)
+
+	// only the details of the page are requested
+	requestMadeInfo := testClient.Calls[1].Arguments.Get(0).(*http.Request)
+	assert.Equal(t, "https://aur.archlinux.org/rpc?arg%5B%5D=b&arg%5B%5D=c&type=info&v=5",
+		requestMadeInfo.URL.String())
+}
+
+func TestClient_GetInfoPage(t *testing.T) {
+	testClient := new(MockedClient)
+
+	c, err := NewClient(WithHTTPClient(testClient))
+	require.NoError(t, err)
+
+	testClient.On("Do", mock.Anything).Return(&http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(bytes.NewBufferString(multiInfoPayload)),
+	}, nil).Once()
+
+	got, err := c.Get(context.Background(), &aur.Query{
+		Needles: []string{"b", "c"},
+		SortBy:  aur.SortName,
+		Offset:  1,
+	})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "c", got[0].Name)
+}
@@ -0,0 +1,107 @@
+package aur
+
+import "sort"
+
+// SortBy specifies the package field to sort query results by.
+type SortBy int
+
+const (
+	SortName SortBy = iota + 1
+	SortVotes
+	SortPopularity
+	SortLastModified
+	SortFirstSubmitted
+)
+
+func (s SortBy) String() string {
+	switch s {
+	case SortName:
+		return "name"
+	case SortVotes:
+		return "votes"
+	case SortPopularity:
+		return "popularity"
+	case SortLastModified:
+		return "modified"
+	case SortFirstSubmitted:
+		return "submitted"
+	default:
+		panic("invalid SortBy")
+	}
+}
+
+// less reports whether a sorts before b in ascending order.
+func (s SortBy) less(a, b *Pkg) bool {
+	switch s {
+	case SortName:
+		return a.Name < b.Name
+	case SortVotes:
+		return a.NumVotes < b.NumVotes
+	case SortPopularity:
+		return a.Popularity < b.Popularity
+	case SortLastModified:
+		return a.LastModified < b.LastModified
+	case SortFirstSubmitted:
+		return a.FirstSubmitted < b.FirstSubmitted
+	default:
+		panic("invalid SortBy")
+	}
+}
+
+// SortPkgs sorts pkgs in place by the given field.
+// Packages comparing equal are ordered by name.
+func SortPkgs(pkgs []Pkg, by SortBy, descending bool) {
+	sort.SliceStable(pkgs, func(i, j int) bool {
+		a, b := &pkgs[i], &pkgs[j]
+		if descending {
+			a, b = b, a
+		}
+
+		if by.less(a, b) {
+			return true
+		}
+
+		if by.less(b, a) {
+			return false
+		}
+
+		return pkgs[i].Name < pkgs[j].Name
+	})
+}
+
+// Paginate sorts pkgs as requested by the query and returns the page selected
+// by its Offset and Limit. pkgs is sorted in place.
+func (q *Query) Paginate(pkgs []Pkg) []Pkg {
+	if q.SortBy != 0 {
+		SortPkgs(pkgs, q.SortBy, q.Descending)
+	}
+
+	if q.Offset > 0 {
+		if q.Offset >= len(pkgs) {
+			return pkgs[:0]
+		}
+
+		pkgs = pkgs[q.Offset:]
+	}
+
+	if q.Limit > 0 && len(pkgs) > q.Limit {
+		pkgs = pkgs[:q.Limit]
+	}
+
+	return pkgs
+}
+
+// Window returns how many results a backend needs to produce, in its own
+// order, to serve the query: Offset+Limit when the results are not sorted and
+// limited, or 0 when all results are needed.
+func (q *Query) Window() int {
+	if q.SortBy != 0 || q.Limit <= 0 {
+		return 0
+	}
+
+	if q.Offset > 0 {
+		return q.Offset + q.Limit
+	}
+
+	return q.Limit
+}
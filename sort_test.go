@@ -0,0 +1,72 @@
+package aur
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func names(pkgs []Pkg) []string {
+	n := make([]string, 0, len(pkgs))
+	for i := range pkgs {
+		n = append(n, pkgs[i].Name)
+	}
+
+	return n
+}
+
+func testPkgs() []Pkg {
+	return []Pkg{
+		{Name: "c", NumVotes: 10, Popularity: 0.5, LastModified: 3, FirstSubmitted: 1},
+		{Name: "a", NumVotes: 10, Popularity: 2, LastModified: 1, FirstSubmitted: 3},
+		{Name: "b", NumVotes: 30, Popularity: 1, LastModified: 2, FirstSubmitted: 2},
+	}
+}
+
+func TestSortPkgs(t *testing.T) {
+	tests := []struct {
+		by         SortBy
+		descending bool
+		want       []string
+	}{
+		{by: SortName, want: []string{"a", "b", "c"}},
+		{by: SortName, descending: true, want: []string{"c", "b", "a"}},
+		{by: SortVotes, want: []string{"a", "c", "b"}},
+		{by: SortVotes, descending: true, want: []string{"b", "a", "c"}}, // ties by name
+		{by: SortPopularity, descending: true, want: []string{"a", "b", "c"}},
+		{by: SortLastModified, want: []string{"a", "b", "c"}},
+		{by: SortFirstSubmitted, want: []string{"c", "b", "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.by.String(), func(t *testing.T) {
+			pkgs := testPkgs()
+			SortPkgs(pkgs, tt.by, tt.descending)
+			assert.Equal(t, tt.want, names(pkgs))
+		})
+	}
+
+	assert.Panics(t, func() { _ = SortBy(0).String() })
+	assert.Panics(t, func() { SortPkgs(testPkgs(), 0, false) })
+}
+
+func TestQueryPaginate(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  Query
+		want   []string
+		window int
+	}{
+		{name: "unchanged", query: Query{}, want: []string{"c", "a", "b"}},
+		{name: "top", query: Query{SortBy: SortVotes, Descending: true, Limit: 1}, want: []string{"b"}},
+		{name: "page", query: Query{SortBy: SortName, Offset: 1, Limit: 1}, want: []string{"b"}},
+		{name: "unsorted page", query: Query{Offset: 1, Limit: 5}, want: []string{"a", "b"}, window: 6},
+		{name: "unsorted limit", query: Query{Limit: 2}, want: []string{"c", "a"}, window: 2},
+		{name: "past the end", query: Query{Offset: 3}, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, names(tt.query.Paginate(testPkgs())))
+			assert.Equal(t, tt.window, tt.query.Window())
+		})
+	}
+}
@@ -0,0 +1,204 @@
+// Package trust scores how much an AUR package can be trusted from the
+// signals found in its metadata.
+package trust
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Jguer/aur"
+	"github.com/Jguer/aur/internal/curve"
+)
+
+const day = 24 * time.Hour
+
+// Weights sets how much each signal counts towards the score.
+// A zero weight ignores the signal.
+type Weights struct {
+	Votes         float64 `json:"Votes"`
+	Popularity    float64 `json:"Popularity"`
+	Age           float64 `json:"Age"`
+	Maintainer    float64 `json:"Maintainer"`
+	OutOfDate     float64 `json:"OutOfDate"`
+	Activity      float64 `json:"Activity"`
+	CoMaintainers float64 `json:"CoMaintainers"`
+}
+
+// Model computes trust scores. Signals that grow without bound, like votes,
+// are mapped to [0, 1) reaching 0.5 at their half value.
+type Model struct {
+	Weights Weights `json:"Weights"`
+	// VotesHalf, PopularityHalf and CoMaintainersHalf are the half values
+	// of the votes, popularity and co-maintainer count.
+	VotesHalf         float64 `json:"VotesHalf"`
+	PopularityHalf    float64 `json:"PopularityHalf"`
+	CoMaintainersHalf float64 `json:"CoMaintainersHalf"`
+	// AgeHalfDays is the half value of the days since first submission.
+	AgeHalfDays float64 `json:"AgeHalfDays"`
+	// ActiveDays is how long after its last modification a package counts as
+	// fully active. Its activity then decreases, reaching 0.5 at twice
+	// ActiveDays.
+	ActiveDays float64 `json:"ActiveDays"`
+}
+
+// DefaultModel weighs maintenance signals over votes and popularity.
+var DefaultModel = Model{
+	Weights: Weights{
+		Votes:         2,
+		Popularity:    1,
+		Age:           1.5,
+		Maintainer:    2,
+		OutOfDate:     1,
+		Activity:      1,
+		CoMaintainers: 0.5,
+	},
+	VotesHalf:         20,
+	PopularityHalf:    0.5,
+	CoMaintainersHalf: 1,
+	AgeHalfDays:       365,
+	ActiveDays:        365,
+}
+
+// Result is the trust score of a package with its explanation.
+type Result struct {
+	Name string `json:"Name"`
+	// Score goes from 0, untrusted, to 100.
+	Score   float64  `json:"Score"`
+	Factors []Factor `json:"Factors"`
+}
+
+// Factor is the part a signal plays in a score.
+type Factor struct {
+	Signal string `json:"Signal"`
+	// Value is the signal mapped to [0, 1].
+	Value  float64 `json:"Value"`
+	Weight float64 `json:"Weight"`
+	// Points is what the signal adds to the score.
+	Points float64 `json:"Points"`
+	Reason string  `json:"Reason"`
+}
+
+// LoadModel reads a JSON model, fields missing from it keep the values of
+// DefaultModel.
+func LoadModel(r io.Reader) (Model, error) {
+	m := DefaultModel
+
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&m); err != nil {
+		return m, fmt.Errorf("invalid trust model: %w", err)
+	}
+
+	if err := m.Validate(); err != nil {
+		return m, err
+	}
+
+	return m, nil
+}
+
+// Validate checks that the model can compute scores.
+func (m *Model) Validate() error {
+	w := &m.Weights
+
+	total := 0.0
+
+	for _, weight := range []float64{
+		w.Votes, w.Popularity, w.Age, w.Maintainer, w.OutOfDate, w.Activity, w.CoMaintainers,
+	} {
+		if weight < 0 {
+			return errors.New("invalid trust model: negative weight")
+		}
+
+		total += weight
+	}
+
+	if total == 0 {
+		return errors.New("invalid trust model: all weights are zero")
+	}
+
+	if m.VotesHalf <= 0 || m.PopularityHalf <= 0 || m.CoMaintainersHalf <= 0 ||
+		m.AgeHalfDays <= 0 || m.ActiveDays <= 0 {
+		return errors.New("invalid trust model: half values must be positive")
+	}
+
+	return nil
+}
+
+// Score computes the trust score of pkg as of now. The same package, model
+// and time always give the same score.
+func (m *Model) Score(pkg *aur.Pkg, now time.Time) Result {
+	w := &m.Weights
+	result := Result{Name: pkg.Name, Factors: []Factor{}}
+
+	add := func(signal string, weight, value float64, reason string) {
+		if weight != 0 {
+			result.Factors = append(result.Factors, Factor{Signal: signal, Value: value, Weight: weight, Reason: reason})
+		}
+	}
+
+	add("votes", w.Votes, curve.Saturate(float64(pkg.NumVotes), m.VotesHalf), fmt.Sprintf("%d votes", pkg.NumVotes))
+	add("popularity", w.Popularity, curve.Saturate(pkg.Popularity, m.PopularityHalf),
+		fmt.Sprintf("popularity %.2f", pkg.Popularity))
+
+	age := daysSince(pkg.FirstSubmitted, now)
+	add("age", w.Age, curve.Saturate(age, m.AgeHalfDays), fmt.Sprintf("submitted %.0f days ago", age))
+
+	if pkg.Maintainer == "" {
+		add("maintainer", w.Maintainer, 0, "orphaned")
+	} else {
+		add("maintainer", w.Maintainer, 1, "maintained by "+pkg.Maintainer)
+	}
+
+	if pkg.OutOfDate == 0 {
+		add("outOfDate", w.OutOfDate, 1, "not flagged out-of-date")
+	} else {
+		add("outOfDate", w.OutOfDate, 0, fmt.Sprintf("flagged out-of-date %.0f days ago", daysSince(pkg.OutOfDate, now)))
+	}
+
+	idle := daysSince(pkg.LastModified, now)
+	add("activity", w.Activity, activity(idle, m.ActiveDays), fmt.Sprintf("last modified %.0f days ago", idle))
+
+	add("coMaintainers", w.CoMaintainers, curve.Saturate(float64(len(pkg.CoMaintainers)), m.CoMaintainersHalf),
+		fmt.Sprintf("%d co-maintainers", len(pkg.CoMaintainers)))
+
+	total := 0.0
+	for i := range result.Factors {
+		total += result.Factors[i].Weight
+	}
+
+	if total == 0 {
+		return result
+	}
+
+	for i := range result.Factors {
+		f := &result.Factors[i]
+		f.Points = 100 * f.Weight * f.Value / total
+		result.Score += f.Points
+	}
+
+	return result
+}
+
+// daysSince returns the days from timestamp to now, 0 for future timestamps.
+func daysSince(timestamp int, now time.Time) float64 {
+	days := float64(now.Sub(time.Unix(int64(timestamp), 0))) / float64(day)
+	if days < 0 {
+		return 0
+	}
+
+	return days
+}
+
+// activity is 1 up to activeDays of idleness, then decreases reaching 0.5 at
+// twice activeDays.
+func activity(idle, activeDays float64) float64 {
+	if idle <= activeDays {
+		return 1
+	}
+
+	return 1 / (1 + (idle-activeDays)/activeDays)
+}
@@ -0,0 +1,108 @@
+package trust
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Jguer/aur"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func daysBefore(days int) int {
+	return int(now.Add(-time.Duration(days) * day).Unix())
+}
+
+func factor(t *testing.T, result *Result, signal string) Factor {
+	t.Helper()
+
+	for _, f := range result.Factors {
+		if f.Signal == signal {
+			return f
+		}
+	}
+
+	require.Failf(t, "missing factor", signal)
+
+	return Factor{}
+}
+
+func TestModelScore(t *testing.T) {
+	t.Parallel()
+
+	trusted := &aur.Pkg{
+		Name: "yay", NumVotes: 2000, Popularity: 50, Maintainer: "jguer",
+		FirstSubmitted: daysBefore(3000), LastModified: daysBefore(10), CoMaintainers: []string{"a", "b"},
+	}
+	suspicious := &aur.Pkg{
+		Name: "yay-fresh", NumVotes: 0, Popularity: 0, Maintainer: "",
+		FirstSubmitted: daysBefore(2), LastModified: daysBefore(2), OutOfDate: daysBefore(1),
+	}
+
+	good := DefaultModel.Score(trusted, now)
+	bad := DefaultModel.Score(suspicious, now)
+
+	assert.Equal(t, "yay", good.Name)
+	assert.Greater(t, good.Score, 85.0)
+	assert.Less(t, bad.Score, 15.0)
+
+	// reproducible
+	assert.Equal(t, good, DefaultModel.Score(trusted, now))
+
+	total := 0.0
+	for _, f := range good.Factors {
+		total += f.Points
+	}
+
+	assert.InDelta(t, good.Score, total, 1e-9)
+
+	assert.Equal(t, "orphaned", factor(t, &bad, "maintainer").Reason)
+	assert.Equal(t, "flagged out-of-date 1 days ago", factor(t, &bad, "outOfDate").Reason)
+	assert.Equal(t, "2 co-maintainers", factor(t, &good, "coMaintainers").Reason)
+	assert.InDelta(t, 2.0/3, factor(t, &good, "coMaintainers").Value, 1e-9)
+}
+
+func TestModelScoreSignals(t *testing.T) {
+	t.Parallel()
+
+	model := DefaultModel
+
+	pkg := &aur.Pkg{Name: "idle", Maintainer: "m", LastModified: daysBefore(730), FirstSubmitted: daysBefore(365)}
+	result := model.Score(pkg, now)
+
+	assert.InDelta(t, 0.5, factor(t, &result, "activity").Value, 1e-9)
+	assert.InDelta(t, 0.5, factor(t, &result, "age").Value, 1e-9)
+
+	// only maintenance counts
+	model.Weights = Weights{Maintainer: 1}
+	result = model.Score(pkg, now)
+	assert.Len(t, result.Factors, 1)
+	assert.InDelta(t, 100, result.Score, 1e-9)
+}
+
+func TestLoadModel(t *testing.T) {
+	t.Parallel()
+
+	model, err := LoadModel(strings.NewReader(`{"weights": {"votes": 5, "coMaintainers": 0}, "votesHalf": 100}`))
+	require.NoError(t, err)
+	assert.Equal(t, 5.0, model.Weights.Votes)
+	assert.Zero(t, model.Weights.CoMaintainers)
+	assert.Equal(t, DefaultModel.Weights.Age, model.Weights.Age)
+	assert.Equal(t, 100.0, model.VotesHalf)
+	assert.Equal(t, DefaultModel.AgeHalfDays, model.AgeHalfDays)
+
+	for _, config := range []string{
+		`{"weight": {}}`,
+		`{"weights": {"votes": -1}}`,
+		`{"weights": {"votes": 0, "popularity": 0, "age": 0, "maintainer": 0, ` +
+			`"outOfDate": 0, "activity": 0, "coMaintainers": 0}}`,
+		`{"activeDays": 0}`,
+		`not json`,
+	} {
+		_, errL := LoadModel(strings.NewReader(config))
+		assert.Error(t, errL, config)
+	}
+}
@@ -0,0 +1,203 @@
+package watch
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Jguer/aur"
+)
+
+// Severity ranks alerts by how likely they are to signal a takeover.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota + 1
+	SeverityWarning
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityCritical:
+		return "critical"
+	default:
+		panic("invalid Severity")
+	}
+}
+
+// MarshalText makes severities readable in JSON alerts.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	for c := SeverityInfo; c <= SeverityCritical; c++ {
+		if c.String() == string(text) {
+			*s = c
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid severity %q", text)
+}
+
+// Fields reported by alerts, besides the names of the aur.Pkg fields.
+const (
+	// FieldPackage is used for a watched package leaving the AUR, or being
+	// submitted while it was removed or missing.
+	FieldPackage = "Package"
+)
+
+// Alert is a change to a watched package.
+type Alert struct {
+	Name     string   `json:"Name"`
+	Field    string   `json:"Field"`
+	Severity Severity `json:"Severity"`
+	Old      string   `json:"Old,omitempty"`
+	New      string   `json:"New,omitempty"`
+	Message  string   `json:"Message"`
+}
+
+// Compare returns the alerts for the changes from old to pkg, either of which
+// may be nil for a package missing from a snapshot.
+//
+// Adopting an orphan, changing the submitter and resubmitting the package
+// are critical. Other maintainer changes, new co-maintainers, and changes to
+// the URL or where the sources come from are warnings. Orphaning, dropped
+// co-maintainers and dependency changes are informational.
+func Compare(old, pkg *aur.Pkg) []Alert {
+	alerts := []Alert{}
+
+	if old == nil {
+		return alerts
+	}
+
+	if pkg == nil {
+		return append(alerts, Alert{
+			Name: old.Name, Field: FieldPackage, Severity: SeverityWarning,
+			Old: old.Version, Message: "package was removed from the AUR",
+		})
+	}
+
+	alert := func(field string, severity Severity, oldValue, newValue, message string) {
+		alerts = append(alerts, Alert{
+			Name: pkg.Name, Field: field, Severity: severity,
+			Old: oldValue, New: newValue, Message: message,
+		})
+	}
+
+	switch {
+	case old.Maintainer == pkg.Maintainer:
+	case old.Maintainer == "":
+		alert("Maintainer", SeverityCritical, "", pkg.Maintainer, "orphan was adopted")
+	case pkg.Maintainer == "":
+		alert("Maintainer", SeverityInfo, old.Maintainer, "", "package was orphaned")
+	default:
+		alert("Maintainer", SeverityWarning, old.Maintainer, pkg.Maintainer, "maintainer changed")
+	}
+
+	added, removed := diffLists(old.CoMaintainers, pkg.CoMaintainers)
+	if len(added) != 0 {
+		alert("CoMaintainers", SeverityWarning, "", strings.Join(added, " "), "co-maintainers added")
+	}
+
+	if len(removed) != 0 {
+		alert("CoMaintainers", SeverityInfo, strings.Join(removed, " "), "", "co-maintainers removed")
+	}
+
+	if old.Submitter != pkg.Submitter {
+		alert("Submitter", SeverityCritical, old.Submitter, pkg.Submitter, "submitter changed")
+	}
+
+	if old.FirstSubmitted != pkg.FirstSubmitted {
+		alert("FirstSubmitted", SeverityCritical, fmt.Sprint(old.FirstSubmitted), fmt.Sprint(pkg.FirstSubmitted),
+			"package was deleted and submitted again")
+	}
+
+	if old.URL != pkg.URL {
+		alert("URL", SeverityWarning, old.URL, pkg.URL, "upstream URL changed")
+	}
+
+	if old.PackageBase != pkg.PackageBase {
+		alert("PackageBase", SeverityWarning, old.PackageBase, pkg.PackageBase, "package base changed")
+	} else if old.URLPath != pkg.URLPath {
+		alert("URLPath", SeverityWarning, old.URLPath, pkg.URLPath, "snapshot URL changed")
+	}
+
+	for _, deps := range []struct {
+		field    string
+		old, new []string
+	}{
+		{"Depends", old.Depends, pkg.Depends},
+		{"MakeDepends", old.MakeDepends, pkg.MakeDepends},
+		{"CheckDepends", old.CheckDepends, pkg.CheckDepends},
+	} {
+		addedDeps, removedDeps := diffLists(deps.old, deps.new)
+		if len(addedDeps) != 0 || len(removedDeps) != 0 {
+			alert(deps.field, SeverityInfo, strings.Join(removedDeps, " "), strings.Join(addedDeps, " "),
+				"dependencies changed")
+		}
+	}
+
+	return alerts
+}
+
+// Reappeared returns the alert for a package submitted again after it was
+// removed from the AUR, removed being its last known version. Anyone can
+// claim a free name, so this is critical.
+func Reappeared(removed, pkg *aur.Pkg) Alert {
+	message := "removed package was submitted again"
+	if pkg.Maintainer != removed.Maintainer {
+		message += " by another maintainer"
+	}
+
+	return Alert{
+		Name: pkg.Name, Field: FieldPackage, Severity: SeverityCritical,
+		Old: removed.Maintainer, New: pkg.Maintainer, Message: message,
+	}
+}
+
+// Appeared returns the alert for a watched package submitted while it was
+// missing from the AUR. Anyone can claim a free name, so this is critical.
+func Appeared(pkg *aur.Pkg) Alert {
+	return Alert{
+		Name: pkg.Name, Field: FieldPackage, Severity: SeverityCritical,
+		New: pkg.Maintainer, Message: "missing package was submitted",
+	}
+}
+
+// diffLists returns the sorted values only in newList and only in oldList.
+func diffLists(oldList, newList []string) (added, removed []string) {
+	inOld := make(map[string]bool, len(oldList))
+	for _, v := range oldList {
+		inOld[v] = true
+	}
+
+	inNew := make(map[string]bool, len(newList))
+	for _, v := range newList {
+		inNew[v] = true
+
+		if !inOld[v] {
+			added = append(added, v)
+			inOld[v] = true
+		}
+	}
+
+	for _, v := range oldList {
+		if !inNew[v] {
+			removed = append(removed, v)
+			inNew[v] = true
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+
+	return added, removed
+}
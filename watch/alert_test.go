@@ -0,0 +1,133 @@
+package watch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Jguer/aur"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+
+	base := aur.Pkg{
+		Name: "yay", PackageBase: "yay", URLPath: "/cgit/aur.git/snapshot/yay.tar.gz",
+		Maintainer: "jguer", Submitter: "jguer", FirstSubmitted: 1, URL: "https://github.com/Jguer/yay",
+		CoMaintainers: []string{"a"}, Depends: []string{"git"},
+	}
+
+	testCases := []struct {
+		desc   string
+		change func(pkg *aur.Pkg)
+		want   []Alert
+	}{
+		{
+			desc:   "version only",
+			change: func(pkg *aur.Pkg) { pkg.Version = "2"; pkg.NumVotes = 10 },
+			want:   []Alert{},
+		},
+		{
+			desc:   "maintainer",
+			change: func(pkg *aur.Pkg) { pkg.Maintainer = "mallory" },
+			want: []Alert{{
+				Name: "yay", Field: "Maintainer", Severity: SeverityWarning,
+				Old: "jguer", New: "mallory", Message: "maintainer changed",
+			}},
+		},
+		{
+			desc:   "orphaned",
+			change: func(pkg *aur.Pkg) { pkg.Maintainer = "" },
+			want: []Alert{{
+				Name: "yay", Field: "Maintainer", Severity: SeverityInfo,
+				Old: "jguer", Message: "package was orphaned",
+			}},
+		},
+		{
+			desc:   "co-maintainers",
+			change: func(pkg *aur.Pkg) { pkg.CoMaintainers = []string{"c", "b"} },
+			want: []Alert{
+				{Name: "yay", Field: "CoMaintainers", Severity: SeverityWarning, New: "b c", Message: "co-maintainers added"},
+				{Name: "yay", Field: "CoMaintainers", Severity: SeverityInfo, Old: "a", Message: "co-maintainers removed"},
+			},
+		},
+		{
+			desc: "resubmitted",
+			change: func(pkg *aur.Pkg) {
+				pkg.Submitter = "mallory"
+				pkg.FirstSubmitted = 2
+			},
+			want: []Alert{
+				{
+					Name: "yay", Field: "Submitter", Severity: SeverityCritical,
+					Old: "jguer", New: "mallory", Message: "submitter changed",
+				},
+				{
+					Name: "yay", Field: "FirstSubmitted", Severity: SeverityCritical,
+					Old: "1", New: "2", Message: "package was deleted and submitted again",
+				},
+			},
+		},
+		{
+			desc: "sources",
+			change: func(pkg *aur.Pkg) {
+				pkg.URL = "https://example.com"
+				pkg.URLPath = "/evil.tar.gz"
+				pkg.Depends = []string{"git", "curl"}
+			},
+			want: []Alert{
+				{
+					Name: "yay", Field: "URL", Severity: SeverityWarning,
+					Old: "https://github.com/Jguer/yay", New: "https://example.com", Message: "upstream URL changed",
+				},
+				{
+					Name: "yay", Field: "URLPath", Severity: SeverityWarning,
+					Old: "/cgit/aur.git/snapshot/yay.tar.gz", New: "/evil.tar.gz", Message: "snapshot URL changed",
+				},
+				{Name: "yay", Field: "Depends", Severity: SeverityInfo, New: "curl", Message: "dependencies changed"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+
+			pkg := base
+			tc.change(&pkg)
+
+			assert.Equal(t, tc.want, Compare(&base, &pkg))
+		})
+	}
+
+	adopted := base
+	adopted.Maintainer = ""
+	pkg := base
+
+	alerts := Compare(&adopted, &pkg)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, SeverityCritical, alerts[0].Severity)
+
+	alerts = Compare(&base, nil)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, FieldPackage, alerts[0].Field)
+
+	assert.Empty(t, Compare(nil, &base))
+}
+
+func TestSeverityText(t *testing.T) {
+	t.Parallel()
+
+	b, err := json.Marshal(Alert{Severity: SeverityCritical})
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"Severity":"critical"`)
+
+	var alert Alert
+	require.NoError(t, json.Unmarshal(b, &alert))
+	assert.Equal(t, SeverityCritical, alert.Severity)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"Severity":"panic"}`), &alert))
+}
@@ -0,0 +1,95 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Jguer/aur"
+)
+
+// Notifier delivers the alerts found by a check.
+type Notifier interface {
+	Notify(ctx context.Context, alerts []Alert) error
+}
+
+// NotifierFunc adapts a function to a Notifier.
+type NotifierFunc func(ctx context.Context, alerts []Alert) error
+
+func (fn NotifierFunc) Notify(ctx context.Context, alerts []Alert) error {
+	return fn(ctx, alerts)
+}
+
+// CallbackNotifier calls fn with the alerts.
+func CallbackNotifier(fn func(alerts []Alert)) Notifier {
+	return NotifierFunc(func(_ context.Context, alerts []Alert) error {
+		fn(alerts)
+
+		return nil
+	})
+}
+
+// JSONNotifier writes the alerts to w as JSON, one alert per line.
+func JSONNotifier(w io.Writer) Notifier {
+	return NotifierFunc(func(_ context.Context, alerts []Alert) error {
+		enc := json.NewEncoder(w)
+
+		for i := range alerts {
+			if err := enc.Encode(&alerts[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// webhookPayload is the body posted to webhooks.
+type webhookPayload struct {
+	Alerts []Alert `json:"Alerts"`
+}
+
+// WebhookNotifier posts the alerts as a JSON object with an "Alerts" array
+// to url. A nil doer uses http.DefaultClient.
+func WebhookNotifier(url string, doer aur.HTTPRequestDoer, editors ...aur.RequestEditorFn) Notifier {
+	if doer == nil {
+		doer = http.DefaultClient
+	}
+
+	return NotifierFunc(func(ctx context.Context, alerts []Alert) error {
+		body, err := json.Marshal(webhookPayload{Alerts: alerts})
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+
+		for _, editor := range editors {
+			if errE := editor(ctx, req); errE != nil {
+				return errE
+			}
+		}
+
+		resp, err := doer.Do(req)
+		if err != nil {
+			return fmt.Errorf("webhook failed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		_, _ = io.Copy(io.Discard, resp.Body)
+
+		if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+			return fmt.Errorf("webhook failed: %s", resp.Status)
+		}
+
+		return nil
+	})
+}
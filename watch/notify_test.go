@@ -0,0 +1,71 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testAlerts = []Alert{
+	{Name: "yay", Field: "Maintainer", Severity: SeverityCritical, New: "mallory", Message: "orphan was adopted"},
+	{Name: "yay", Field: "URL", Severity: SeverityWarning, Old: "a", New: "b", Message: "upstream URL changed"},
+}
+
+func TestJSONNotifier(t *testing.T) {
+	t.Parallel()
+
+	var b bytes.Buffer
+
+	require.NoError(t, JSONNotifier(&b).Notify(context.Background(), testAlerts))
+
+	dec := json.NewDecoder(&b)
+
+	for i := range testAlerts {
+		var alert Alert
+		require.NoError(t, dec.Decode(&alert))
+		assert.Equal(t, testAlerts[i], alert)
+	}
+
+	assert.False(t, dec.More())
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	t.Parallel()
+
+	var (
+		payload webhookPayload
+		header  http.Header
+	)
+
+	status := http.StatusNoContent
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	token := func(ctx context.Context, req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer secret")
+
+		return nil
+	}
+
+	notifier := WebhookNotifier(server.URL, nil, token)
+
+	require.NoError(t, notifier.Notify(context.Background(), testAlerts))
+	assert.Equal(t, testAlerts, payload.Alerts)
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	assert.Equal(t, "Bearer secret", header.Get("Authorization"))
+
+	status = http.StatusInternalServerError
+	assert.Error(t, notifier.Notify(context.Background(), testAlerts))
+}
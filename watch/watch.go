@@ -0,0 +1,270 @@
+// Package watch alerts on ownership and source changes of AUR packages,
+// which often precede supply-chain attacks.
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/Jguer/aur"
+)
+
+// batchSize is the number of packages looked up per request.
+const batchSize = 100
+
+// InfoClient looks up packages by name. It is implemented by the rpc and
+// metadata clients.
+type InfoClient interface {
+	Info(ctx context.Context, names []string) ([]aur.Pkg, error)
+}
+
+// Watcher compares successive snapshots of the watched packages.
+// The first check only records the packages as they are.
+type Watcher struct {
+	client      InfoClient
+	names       []string
+	notifiers   []Notifier
+	minSeverity Severity
+	statePath   string
+
+	mu sync.Mutex
+	// state holds the packages as of the last check, nil before the first.
+	state *snapshot
+}
+
+// snapshot is the state of the watched packages kept between checks.
+type snapshot struct {
+	Packages map[string]aur.Pkg `json:"Packages"`
+	// Removed holds the last known version of the watched packages that left
+	// the AUR, so their name being claimed again is noticed.
+	Removed map[string]aur.Pkg `json:"Removed,omitempty"`
+	// Missing lists the watched names never found in the AUR, which anyone
+	// may claim.
+	Missing []string `json:"Missing,omitempty"`
+}
+
+// Option allows setting custom parameters during construction.
+type Option func(*Watcher) error
+
+// New creates a watcher of the packages called names.
+func New(client InfoClient, names []string, opts ...Option) (*Watcher, error) {
+	w := &Watcher{
+		client:      client,
+		names:       append([]string{}, names...),
+		notifiers:   []Notifier{},
+		minSeverity: SeverityInfo,
+		statePath:   "",
+		state:       nil,
+	}
+
+	for _, o := range opts {
+		if err := o(w); err != nil {
+			return nil, err
+		}
+	}
+
+	if w.statePath != "" {
+		if err := w.loadState(); err != nil {
+			return nil, err
+		}
+	}
+
+	return w, nil
+}
+
+// WithNotifier delivers alerts to n, in addition to returning them from
+// Check.
+func WithNotifier(n Notifier) Option {
+	return func(w *Watcher) error {
+		w.notifiers = append(w.notifiers, n)
+
+		return nil
+	}
+}
+
+// WithMinSeverity drops alerts below severity.
+func WithMinSeverity(severity Severity) Option {
+	return func(w *Watcher) error {
+		if severity < SeverityInfo || severity > SeverityCritical {
+			return fmt.Errorf("invalid severity: %d", severity)
+		}
+
+		w.minSeverity = severity
+
+		return nil
+	}
+}
+
+// WithStateFile keeps the last snapshot in the file at path, so changes are
+// noticed across runs.
+func WithStateFile(path string) Option {
+	return func(w *Watcher) error {
+		w.statePath = path
+
+		return nil
+	}
+}
+
+// Check looks up the watched packages and reports their changes since the
+// previous check, ordered by decreasing severity. Removed and missing
+// packages are remembered, so their name being submitted raises a critical
+// alert. The new snapshot is only kept once every notifier succeeded, so failed
+// deliveries are retried by the next check.
+func (w *Watcher) Check(ctx context.Context) ([]Alert, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	current, err := w.lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	next, alerts := w.diff(current)
+
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].Severity > alerts[j].Severity })
+
+	if len(alerts) != 0 {
+		for _, n := range w.notifiers {
+			if errN := n.Notify(ctx, alerts); errN != nil {
+				return alerts, errN
+			}
+		}
+	}
+
+	w.state = next
+
+	if w.statePath != "" {
+		if errS := w.saveState(); errS != nil {
+			return alerts, errS
+		}
+	}
+
+	return alerts, nil
+}
+
+// diff returns the snapshot following the current packages, and the alerts
+// for their changes since the last check.
+func (w *Watcher) diff(current map[string]aur.Pkg) (*snapshot, []Alert) {
+	next := &snapshot{Packages: current, Removed: map[string]aur.Pkg{}, Missing: []string{}}
+	alerts := []Alert{}
+
+	// the first check only records the packages
+	prev := w.state
+	if prev == nil {
+		prev = &snapshot{}
+	}
+
+	wasMissing := make(map[string]bool, len(prev.Missing))
+	for _, name := range prev.Missing {
+		wasMissing[name] = true
+	}
+
+	add := func(alert Alert) {
+		if alert.Severity >= w.minSeverity && w.state != nil {
+			alerts = append(alerts, alert)
+		}
+	}
+
+	missing := map[string]bool{}
+
+	for _, name := range w.names {
+		pkg, okNew := current[name]
+		old, okOld := prev.Packages[name]
+		removed, okRemoved := prev.Removed[name]
+
+		switch {
+		case okOld && okNew:
+			for _, alert := range Compare(&old, &pkg) {
+				add(alert)
+			}
+		case okOld:
+			add(Compare(&old, nil)[0])
+			next.Removed[name] = old
+		case okRemoved && okNew:
+			add(Reappeared(&removed, &pkg))
+		case okRemoved:
+			next.Removed[name] = removed
+		case okNew:
+			// names added to the watch list since the last check are recorded
+			if wasMissing[name] {
+				add(Appeared(&pkg))
+			}
+		case !missing[name]:
+			missing[name] = true
+			next.Missing = append(next.Missing, name)
+		}
+	}
+
+	return next, alerts
+}
+
+// lookup returns the watched packages found in the AUR.
+func (w *Watcher) lookup(ctx context.Context) (map[string]aur.Pkg, error) {
+	pkgs := make(map[string]aur.Pkg, len(w.names))
+
+	for n := 0; n < len(w.names); n += batchSize {
+		end := n + batchSize
+		if end > len(w.names) {
+			end = len(w.names)
+		}
+
+		found, err := w.client.Info(ctx, w.names[n:end])
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range found {
+			pkgs[found[i].Name] = found[i]
+		}
+	}
+
+	return pkgs, nil
+}
+
+func (w *Watcher) loadState() error {
+	b, err := os.ReadFile(w.statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(b, &w.state); err != nil {
+		return fmt.Errorf("unable to read watch state: %w", err)
+	}
+
+	return nil
+}
+
+func (w *Watcher) saveState() error {
+	b, err := json.Marshal(w.state)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(w.statePath), filepath.Base(w.statePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), w.statePath)
+}
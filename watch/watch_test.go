@@ -0,0 +1,247 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/Jguer/aur"
+	"github.com/Jguer/aur/metadata"
+	"github.com/Jguer/aur/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ InfoClient = (*rpc.Client)(nil)
+	_ InfoClient = (*metadata.Client)(nil)
+)
+
+// fakeClient serves its packages and records the looked up names.
+type fakeClient struct {
+	pkgs    map[string]aur.Pkg
+	lookups [][]string
+}
+
+func (c *fakeClient) Info(ctx context.Context, names []string) ([]aur.Pkg, error) {
+	c.lookups = append(c.lookups, names)
+
+	pkgs := []aur.Pkg{}
+	for _, name := range names {
+		if pkg, ok := c.pkgs[name]; ok {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+
+	return pkgs, nil
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{pkgs: map[string]aur.Pkg{
+		"yay":  {Name: "yay", Maintainer: "jguer", Submitter: "jguer", URL: "https://github.com/Jguer/yay"},
+		"paru": {Name: "paru", Maintainer: "", Submitter: "morganamilo"},
+	}}
+}
+
+func TestWatcherCheck(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	client := newFakeClient()
+	notified := [][]Alert{}
+
+	w, err := New(client, []string{"yay", "paru", "missing"},
+		WithNotifier(CallbackNotifier(func(alerts []Alert) { notified = append(notified, alerts) })))
+	require.NoError(t, err)
+
+	alerts, err := w.Check(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+
+	yay := client.pkgs["yay"]
+	yay.URL = "https://example.com"
+	client.pkgs["yay"] = yay
+
+	paru := client.pkgs["paru"]
+	paru.Maintainer = "mallory"
+	client.pkgs["paru"] = paru
+
+	alerts, err = w.Check(ctx)
+	require.NoError(t, err)
+	require.Len(t, alerts, 2)
+	assert.Equal(t, "paru", alerts[0].Name)
+	assert.Equal(t, SeverityCritical, alerts[0].Severity)
+	assert.Equal(t, "URL", alerts[1].Field)
+	assert.Equal(t, [][]Alert{alerts}, notified)
+
+	delete(client.pkgs, "yay")
+
+	alerts, err = w.Check(ctx)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, FieldPackage, alerts[0].Field)
+
+	// nothing changed, nothing notified
+	alerts, err = w.Check(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+	assert.Len(t, notified, 2)
+}
+
+func TestWatcherRemovedReappears(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	client := newFakeClient()
+	statePath := filepath.Join(t.TempDir(), "watch.json")
+
+	w, err := New(client, []string{"yay"}, WithStateFile(statePath), WithMinSeverity(SeverityCritical))
+	require.NoError(t, err)
+
+	_, err = w.Check(ctx)
+	require.NoError(t, err)
+
+	yay := client.pkgs["yay"]
+	delete(client.pkgs, "yay")
+
+	// the removal is below the minimum severity, but still remembered
+	alerts, err := w.Check(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+
+	alerts, err = w.Check(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+
+	// the name is claimed by someone else, seen by a later run
+	yay.Maintainer = "mallory"
+	client.pkgs["yay"] = yay
+
+	w, err = New(client, []string{"yay"}, WithStateFile(statePath))
+	require.NoError(t, err)
+
+	alerts, err = w.Check(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Alert{{
+		Name: "yay", Field: FieldPackage, Severity: SeverityCritical, Old: "jguer", New: "mallory",
+		Message: "removed package was submitted again by another maintainer",
+	}}, alerts)
+
+	// the package is watched as usual again
+	alerts, err = w.Check(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+}
+
+func TestWatcherMissingAppears(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	client := newFakeClient()
+	statePath := filepath.Join(t.TempDir(), "watch.json")
+
+	w, err := New(client, []string{"yay", "yay-ng"}, WithStateFile(statePath))
+	require.NoError(t, err)
+
+	alerts, err := w.Check(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+
+	// the free name is claimed, seen by a later run
+	client.pkgs["yay-ng"] = aur.Pkg{Name: "yay-ng", Maintainer: "mallory"}
+
+	w, err = New(client, []string{"yay", "yay-ng", "paru"}, WithStateFile(statePath))
+	require.NoError(t, err)
+
+	// paru was not watched before, it is only recorded
+	alerts, err = w.Check(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Alert{{
+		Name: "yay-ng", Field: FieldPackage, Severity: SeverityCritical, New: "mallory",
+		Message: "missing package was submitted",
+	}}, alerts)
+
+	alerts, err = w.Check(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+}
+
+func TestWatcherMinSeverity(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	client := newFakeClient()
+
+	w, err := New(client, []string{"yay"}, WithMinSeverity(SeverityCritical))
+	require.NoError(t, err)
+
+	_, err = w.Check(ctx)
+	require.NoError(t, err)
+
+	yay := client.pkgs["yay"]
+	yay.URL = "https://example.com"
+	client.pkgs["yay"] = yay
+
+	alerts, err := w.Check(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+
+	_, err = New(client, nil, WithMinSeverity(0))
+	assert.Error(t, err)
+}
+
+func TestWatcherStateFile(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	client := newFakeClient()
+	statePath := filepath.Join(t.TempDir(), "watch.json")
+
+	w, err := New(client, []string{"yay"}, WithStateFile(statePath))
+	require.NoError(t, err)
+
+	_, err = w.Check(ctx)
+	require.NoError(t, err)
+
+	yay := client.pkgs["yay"]
+	yay.Maintainer = "mallory"
+	client.pkgs["yay"] = yay
+
+	// a failed delivery keeps the previous state
+	failing := NotifierFunc(func(context.Context, []Alert) error { return errors.New("unreachable") })
+
+	w, err = New(client, []string{"yay"}, WithStateFile(statePath), WithNotifier(failing))
+	require.NoError(t, err)
+
+	alerts, err := w.Check(ctx)
+	assert.Error(t, err)
+	assert.Len(t, alerts, 1)
+
+	w, err = New(client, []string{"yay"}, WithStateFile(statePath))
+	require.NoError(t, err)
+
+	alerts, err = w.Check(ctx)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, "mallory", alerts[0].New)
+}
+
+func TestWatcherBatches(t *testing.T) {
+	t.Parallel()
+
+	client := newFakeClient()
+	names := make([]string, batchSize+1)
+
+	for i := range names {
+		names[i] = "yay"
+	}
+
+	w, err := New(client, names)
+	require.NoError(t, err)
+
+	_, err = w.Check(context.Background())
+	require.NoError(t, err)
+	require.Len(t, client.lookups, 2)
+	assert.Len(t, client.lookups[1], 1)
+}